@@ -2,14 +2,21 @@ package igo
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"reflect"
 	"strings"
+	"time"
 
 	"log/slog"
 
+	"github.com/gin-gonic/gin"
 	"github.com/parkingwang/igo/internal/trace"
 	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/auth"
+	"github.com/parkingwang/igo/pkg/http/web/middleware"
 	"github.com/parkingwang/igo/pkg/http/web/oas"
+	"github.com/parkingwang/igo/pkg/http/web/ratelimit"
 	"github.com/parkingwang/igo/pkg/store/database"
 	"github.com/parkingwang/igo/pkg/store/redis"
 	"go.uber.org/fx"
@@ -23,6 +30,8 @@ type Application struct {
 	fxProvides    []any
 	fxInvokeFuncs []any
 	info          AppInfo
+	// ProvideHandlerValue 构造的对象 fx启动时填充
+	handlerValues []any
 }
 
 func New(info AppInfo) *Application {
@@ -54,7 +63,7 @@ func New(info AppInfo) *Application {
 	)
 
 	if err != nil {
-		slog.Error("init tracer povider failed", err)
+		slog.Error("init tracer povider failed", slog.String("err", err.Error()))
 		os.Exit(1)
 	}
 	otel.SetTextMapPropagator(b3.New())
@@ -62,7 +71,7 @@ func New(info AppInfo) *Application {
 
 	// 自动加载pkg/store
 	if err := initPkgStore(); err != nil {
-		slog.Error("init pkg/store failed", err)
+		slog.Error("init pkg/store failed", slog.String("err", err.Error()))
 		os.Exit(1)
 	}
 
@@ -74,6 +83,84 @@ func (app *Application) Provide(provide ...any) {
 	app.fxProvides = append(app.fxProvides, provide...)
 }
 
+// ProvideController 注册控制器的构造函数 控制器的依赖由fx注入
+// 通过 Controllers 获取所有控制器并注册到路由
+func (app *Application) ProvideController(ctors ...any) {
+	for _, v := range ctors {
+		app.fxProvides = append(app.fxProvides, fx.Annotate(
+			v,
+			fx.As(new(any)),
+			fx.ResultTags(`group:"controllers"`),
+		))
+	}
+}
+
+// ProvideHandlerValue 注册构造函数 构造的对象由fx注入 同时可以作为rpc方法的参数
+// CreateWebServer 创建的服务自动通过 web.WithValues 注册
+//
+//	app.ProvideHandlerValue(NewUserRepo)
+//	srv.Router().Get("/user/:id", func(ctx context.Context, in *In, repo *UserRepo) (*User, error) {...})
+func (app *Application) ProvideHandlerValue(ctors ...any) {
+	for _, v := range ctors {
+		tp := reflect.TypeOf(v)
+		if tp == nil || tp.Kind() != reflect.Func {
+			panic(fmt.Sprintf("ProvideHandlerValue: constructor must be func, got %T", v))
+		}
+		app.fxProvides = append(app.fxProvides, v)
+		for i := 0; i < tp.NumOut(); i++ {
+			if out := tp.Out(i); out != rtypeError {
+				app.fxProvides = append(app.fxProvides, fx.Annotate(
+					handlerValueOf(out),
+					fx.ResultTags(`group:"handler.values"`),
+				))
+			}
+		}
+	}
+}
+
+var (
+	rtypeError = reflect.TypeOf((*error)(nil)).Elem()
+	rtypeAny   = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// handlerValueOf 生成 func(T) any 将构造的对象加入handler.values分组
+func handlerValueOf(t reflect.Type) any {
+	fn := reflect.FuncOf([]reflect.Type{t}, []reflect.Type{rtypeAny}, false)
+	return reflect.MakeFunc(fn, func(args []reflect.Value) []reflect.Value {
+		v := reflect.New(rtypeAny).Elem()
+		v.Set(args[0])
+		return []reflect.Value{v}
+	}).Interface()
+}
+
+type handlerValues struct {
+	fx.In
+	Items []any `group:"handler.values"`
+}
+
+func (app *Application) setHandlerValues(v handlerValues) {
+	app.handlerValues = v.Items
+}
+
+// Controllers 通过ProvideController注册的控制器 在web服务的构造函数中声明
+//
+//	app.Run(func(cs igo.Controllers) igo.Servicer {
+//		srv := app.CreateWebServer()
+//		cs.Register(srv.Router())
+//		return srv
+//	})
+type Controllers struct {
+	fx.In
+	Items []any `group:"controllers"`
+}
+
+// Register 将所有控制器注册到路由
+func (cs Controllers) Register(r web.Router) {
+	for _, c := range cs.Items {
+		r.Controller(c)
+	}
+}
+
 // Invoke 注册调用
 func (app *Application) Invoke(funcs ...any) {
 	app.fxInvokeFuncs = append(app.fxInvokeFuncs, funcs...)
@@ -82,8 +169,8 @@ func (app *Application) Invoke(funcs ...any) {
 func fxLifecycle(srvs []Servicer, lc fx.Lifecycle) {
 	for _, v := range srvs {
 		lc.Append(fx.Hook{
-			OnStart: v.Start,
-			OnStop:  v.Stop,
+			OnStart: safeHook(v.Start),
+			OnStop:  safeHook(v.Stop),
 		})
 	}
 }
@@ -92,6 +179,20 @@ func (app *Application) Run(srv ...any) {
 	for _, v := range srv {
 		app.fxProvides = append(app.fxProvides, asServicer(v))
 	}
+	invokes := []any{
+		fx.Annotate(
+			fxLifecycle,
+			fx.ParamTags(`group:"services"`),
+		),
+	}
+	// 平滑重启 需要在所有服务之后注册
+	if cfg := Conf().Child("app"); cfg != nil && cfg.GetBool("gracefulRestart.enable") {
+		timeout := cfg.GetDuration("gracefulRestart.timeout")
+		if timeout <= 0 {
+			timeout = time.Minute
+		}
+		invokes = append(invokes, gracefulRestart(timeout))
+	}
 	fxapp := fx.New(
 		fx.WithLogger(func() fxevent.Logger {
 			return &fxInjectLogger{
@@ -99,13 +200,10 @@ func (app *Application) Run(srv ...any) {
 			}
 		}),
 		fx.Provide(app.fxProvides...),
+		// 在服务构造之前获取rpc方法的参数
+		fx.Invoke(app.setHandlerValues),
 		fx.Invoke(app.fxInvokeFuncs...),
-		fx.Invoke(
-			fx.Annotate(
-				fxLifecycle,
-				fx.ParamTags(`group:"services"`),
-			),
-		),
+		fx.Invoke(invokes...),
 	)
 	fxapp.Run()
 }
@@ -126,21 +224,21 @@ func (m *fxInjectLogger) LogEvent(event fxevent.Event) {
 	switch e := event.(type) {
 	case *fxevent.Provided:
 		if e.Err != nil {
-			m.baselog.Error("provided error encountered while applying options", e.Err)
+			m.baselog.Error("provided error encountered while applying options", slog.String("err", e.Err.Error()))
 		}
 	case *fxevent.Invoked:
 		if e.Err != nil {
-			m.baselog.Error("invoked failed", e.Err, slog.String("function", e.FunctionName))
+			m.baselog.Error("invoked failed", slog.String("err", e.Err.Error()), slog.String("function", e.FunctionName))
 		}
 	case *fxevent.Stopping:
 		m.baselog.Info("received signal", slog.String("signal", strings.ToUpper(e.Signal.String())))
 	case *fxevent.Stopped:
 		if e.Err != nil {
-			m.baselog.Error("stop failed", e.Err)
+			m.baselog.Error("stop failed", slog.String("err", e.Err.Error()))
 		}
 	case *fxevent.Started:
 		if e.Err != nil {
-			m.baselog.Error("start failed", e.Err)
+			m.baselog.Error("start failed", slog.String("err", e.Err.Error()))
 		} else {
 			m.baselog.Info("started")
 		}
@@ -154,9 +252,20 @@ type Servicer interface {
 }
 
 func (app *Application) CreateWebServer(opts ...web.Option) *web.Server {
+	// 未配置server.web时同样使用的默认选项
+	defaults := []web.Option{
+		web.WithValues(app.handlerValues...),
+		web.WithPanicHandler(func(c *gin.Context, err *web.PanicError) {
+			ReportError(c, err)
+		}),
+		web.WithErrorHandler(func(c *gin.Context, err error) {
+			ReportError(c, err)
+		}),
+		auth.WithPrincipal(),
+	}
 	cfg := Conf().Child("server.web")
 	if cfg == nil {
-		return web.New()
+		return web.New(append(defaults, opts...)...)
 	}
 	var docinfo *oas.DocInfo
 	if cfg.GetBool("openapi") {
@@ -168,10 +277,134 @@ func (app *Application) CreateWebServer(opts ...web.Option) *web.Server {
 		}
 	}
 
-	baseOpts := []web.Option{
+	baseOpts := append(defaults,
 		web.WithAddr(cfg.GetString("addr")),
 		web.WithDumpRequestBody(cfg.GetBool("dumpRequest")),
 		web.WithOpenAPI(docinfo),
+	)
+	if listens := cfg.GetStringSlice("listen"); len(listens) > 0 {
+		baseOpts = append(baseOpts, web.WithListen(listens...))
+	}
+	if cfg.IsSet("tls") {
+		var t web.TLSOption
+		if err := cfg.Decode("tls", &t); err != nil {
+			slog.Error("decode server.web.tls failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithTLS(t))
+	}
+	baseOpts = append(baseOpts, web.WithH2C(cfg.GetBool("h2c")))
+	if cfg.IsSet("security") {
+		var sc middleware.SecurityConfig
+		if err := cfg.Decode("security", &sc); err != nil {
+			slog.Error("decode server.web.security failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithMiddleware(middleware.Security(sc)))
+	}
+	if cfg.IsSet("cors") {
+		var cc middleware.CORSConfig
+		if err := cfg.Decode("cors", &cc); err != nil {
+			slog.Error("decode server.web.cors failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		if err := cc.Validate(); err != nil {
+			slog.Error("invalid server.web.cors", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithMiddleware(middleware.CORS(cc)))
+	}
+	if cfg.IsSet("compression") {
+		var cc middleware.CompressionConfig
+		if err := cfg.Decode("compression", &cc); err != nil {
+			slog.Error("decode server.web.compression failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithMiddleware(middleware.Compression(cc)))
+	}
+	if cfg.IsSet("dump") {
+		var d web.DumpOption
+		if err := cfg.Decode("dump", &d); err != nil {
+			slog.Error("decode server.web.dump failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithDump(d))
+	}
+	if cfg.IsSet("bodyLimit") {
+		var b web.BodyLimitOption
+		if err := cfg.Decode("bodyLimit", &b); err != nil {
+			slog.Error("decode server.web.bodyLimit failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithBodyLimit(b))
+	}
+	if cfg.IsSet("versioning") {
+		var v web.VersionOption
+		if err := cfg.Decode("versioning", &v); err != nil {
+			slog.Error("decode server.web.versioning failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithVersioning(v))
+	}
+	if cfg.IsSet("timeout") {
+		var t web.TimeoutOption
+		if err := cfg.Decode("timeout", &t); err != nil {
+			slog.Error("decode server.web.timeout failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithTimeout(t))
+	}
+	for _, name := range cfg.GetStringSlice("codecs") {
+		if c, ok := web.LookupCodec(name); ok {
+			baseOpts = append(baseOpts, web.WithCodec(c))
+		} else {
+			slog.Warn("unknown codec", slog.String("codec", name))
+		}
+	}
+	if cfg.IsSet("auth") {
+		authcfg := make(map[string]auth.Config)
+		if err := cfg.Decode("auth", &authcfg); err != nil {
+			slog.Error("decode server.web.auth failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		for name, c := range authcfg {
+			a, err := auth.New(c)
+			if err != nil {
+				slog.Error("init authenticator failed", slog.String("name", name), slog.String("err", err.Error()))
+				os.Exit(1)
+			}
+			baseOpts = append(baseOpts, web.WithAuthenticator(name, a))
+		}
+	}
+	if cfg.IsSet("rbac") {
+		table := make(map[string][]string)
+		if err := cfg.Decode("rbac", &table); err != nil {
+			slog.Error("decode server.web.rbac failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		baseOpts = append(baseOpts, web.WithAuthorizer(auth.NewRBAC(table)))
+	}
+	if cfg.IsSet("ratelimit") {
+		limits := make(map[string]ratelimit.Config)
+		if err := cfg.Decode("ratelimit", &limits); err != nil {
+			slog.Error("decode server.web.ratelimit failed", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		for name, c := range limits {
+			l, err := ratelimit.FromConfig(c)
+			if err != nil {
+				slog.Error("init rate limiter failed", slog.String("name", name), slog.String("err", err.Error()))
+				os.Exit(1)
+			}
+			baseOpts = append(baseOpts, web.WithRateLimiter(name, l))
+		}
+	}
+	if name := cfg.GetString("response.envelope"); name != "" && name != "none" {
+		if e, ok := web.LookupEnvelope(name); ok {
+			baseOpts = append(baseOpts, web.WithEnvelope(e))
+		} else {
+			slog.Warn("unknown response envelope", slog.String("envelope", name))
+		}
 	}
 	return web.New(append(baseOpts, opts...)...)
 }
@@ -0,0 +1,48 @@
+package igo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+	"go.uber.org/fx"
+)
+
+type testRepo struct{ name string }
+
+func TestProvideHandlerValue(t *testing.T) {
+	app := &Application{}
+	app.ProvideHandlerValue(func() (*testRepo, error) {
+		return &testRepo{name: "repo"}, nil
+	})
+	var repo *testRepo
+	err := fx.New(
+		fx.NopLogger,
+		fx.Provide(app.fxProvides...),
+		fx.Invoke(app.setHandlerValues),
+		// 其他构造函数仍然可以注入
+		fx.Populate(&repo),
+	).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo == nil || len(app.handlerValues) != 1 || app.handlerValues[0] != repo {
+		t.Fatalf("unexpected handler values %v", app.handlerValues)
+	}
+
+	srv := web.New(web.WithValues(app.handlerValues...))
+	srv.Router().Get("/", func(ctx context.Context, in *web.Empty, r *testRepo) (string, error) {
+		return r.name, nil
+	})
+	h, err := srv.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `"repo"` {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,246 @@
+// igodoc 提取rpc方法和控制器的注释 生成注册到文档的代码
+//
+// 在路由所在的包中添加
+//
+//	//go:generate go run github.com/parkingwang/igo/cmd/igodoc
+//
+// rpc风格的函数和方法 func(ctx context.Context, in *T, deps...) (out, error) 以及这些方法所属的控制器类型会被提取
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	output := flag.String("o", "igodoc_gen.go", "output file name")
+	dir := flag.String("dir", ".", "package directory")
+	flag.Parse()
+
+	pkgName, comments, err := extract(*dir, *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(pkgName, comments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// extract 返回包名和 运行时名称->注释
+func extract(dir, output string) (string, map[string]string, error) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return "", nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("no go files in %s", dir)
+	}
+	pkgName := files[0].Name.Name
+	pkgPath := pkgName
+	if pkgName != "main" {
+		if pkgPath, err = importPath(dir); err != nil {
+			return "", nil, err
+		}
+	}
+
+	comments := make(map[string]string)
+	types := make(map[string]bool)
+	typeDocs := make(map[string]string)
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if !isRPC(d.Type) {
+					continue
+				}
+				key := pkgPath + "."
+				if d.Recv != nil && len(d.Recv.List) == 1 {
+					recv, ptr := receiver(d.Recv.List[0].Type)
+					if recv == "" {
+						continue
+					}
+					types[recv] = true
+					if ptr {
+						key += "(*" + recv + ")."
+					} else {
+						key += recv + "."
+					}
+				}
+				if text := docText(d.Doc, d.Name.Name); text != "" {
+					comments[key+d.Name.Name] = text
+				}
+			case *ast.GenDecl:
+				if d.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range d.Specs {
+					ts := spec.(*ast.TypeSpec)
+					doc := ts.Doc
+					if doc == nil && len(d.Specs) == 1 {
+						doc = d.Doc
+					}
+					if text := docText(doc, ts.Name.Name); text != "" {
+						typeDocs[ts.Name.Name] = text
+					}
+				}
+			}
+		}
+	}
+	for name := range types {
+		if text, ok := typeDocs[name]; ok {
+			comments[pkgPath+"."+name] = text
+		}
+	}
+	return pkgName, comments, nil
+}
+
+func importPath(dir string) (string, error) {
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", ".")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go list: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// isRPC 与web.checkHandleValid一致 第一个参数为context.Context
+// 第二个参数为结构体指针或切片 返回error或(out, error)
+func isRPC(ft *ast.FuncType) bool {
+	if ft.Params == nil || ft.Results == nil {
+		return false
+	}
+	params := expandFields(ft.Params)
+	if len(params) < 2 || !isContext(params[0]) {
+		return false
+	}
+	switch t := params[1].(type) {
+	case *ast.StarExpr:
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return false
+		}
+	default:
+		return false
+	}
+	results := expandFields(ft.Results)
+	if n := len(results); n == 0 || n > 2 {
+		return false
+	}
+	id, ok := results[len(results)-1].(*ast.Ident)
+	return ok && id.Name == "error"
+}
+
+// isContext context.Context 类型
+func isContext(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Context" {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "context"
+}
+
+// expandFields 按参数展开类型 如 (a, b int) -> int, int
+func expandFields(fl *ast.FieldList) []ast.Expr {
+	var list []ast.Expr
+	for _, f := range fl.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			list = append(list, f.Type)
+		}
+	}
+	return list
+}
+
+// receiver 接收者的类型名以及是否为指针
+func receiver(expr ast.Expr) (string, bool) {
+	ptr := false
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr, ptr = star.X, true
+	}
+	// 泛型类型的名称无法对应 忽略
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name, ptr
+	}
+	return "", false
+}
+
+// docText 去掉注释开头的名称 如 "GetUser 获取单个用户" -> "获取单个用户"
+// 只有分隔符的行 如 "////////" "-----" 会被忽略
+func docText(doc *ast.CommentGroup, name string) string {
+	if doc == nil {
+		return ""
+	}
+	lines := strings.Split(doc.Text(), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !isSeparator(line) {
+			kept = append(kept, line)
+		}
+	}
+	text := strings.TrimSpace(strings.Join(kept, "\n"))
+	if rest, ok := strings.CutPrefix(text, name); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\n') {
+		text = strings.TrimSpace(rest)
+	}
+	return text
+}
+
+// isSeparator 由重复的标点组成的行
+func isSeparator(line string) bool {
+	line = strings.TrimSpace(line)
+	if len(line) < 3 {
+		return false
+	}
+	return strings.Trim(line, "/-=*#_~+") == ""
+}
+
+func generate(pkgName string, comments map[string]string) ([]byte, error) {
+	keys := make([]string, 0, len(comments))
+	for k := range comments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by igodoc. DO NOT EDIT.\n\npackage %s\n\n", pkgName)
+	b.WriteString("import \"github.com/parkingwang/igo/pkg/http/web\"\n\n")
+	b.WriteString("func init() {\n\tweb.RegisterDocComments(map[string]string{\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\t\t%s: %s,\n", strconv.Quote(k), strconv.Quote(comments[k]))
+	}
+	b.WriteString("\t})\n}\n")
+	return format.Source(b.Bytes())
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+const testSrc = `package demo
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ////////////////////
+// GetUser 获取用户
+// --------
+// 按id查询
+func GetUser(ctx context.Context, in *Req) (*Resp, error) { return nil, nil }
+
+// Delete 删除
+func Delete(ctx context.Context, ids []int) error { return nil }
+
+// 中间件
+func middle(c *gin.Context) {}
+
+// 服务
+func Start(ctx context.Context) error { return nil }
+
+// 不是rpc
+func Fetch(ctx context.Context, id int) (int, error) { return 0, nil }
+
+// 三个返回值
+func Many(ctx context.Context, in *Req) (int, int, error) { return 0, 0, nil }
+`
+
+func TestExtractRPC(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "demo.go", testSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"GetUser": "获取用户\n按id查询",
+		"Delete":  "删除",
+	}
+	got := make(map[string]string)
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.FuncDecl); ok && isRPC(d.Type) {
+			got[d.Name.Name] = docText(d.Doc, d.Name.Name)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %q, want %q", k, got[k], v)
+		}
+	}
+}
@@ -13,7 +13,7 @@ var defaultConfig config.Provider
 func SetConfig(path string) {
 	c, err := config.LoadConfig(path)
 	if err != nil {
-		slog.Error("load config failed", err)
+		slog.Error("load config failed", slog.String("err", err.Error()))
 		os.Exit(1)
 	}
 	// 设置默认值
@@ -0,0 +1,15 @@
+// Code generated by igodoc. DO NOT EDIT.
+
+package main
+
+import "github.com/parkingwang/igo/pkg/http/web"
+
+func init() {
+	web.RegisterDocComments(map[string]string{
+		"main.(*UserController).Create": "新增用户",
+		"main.(*UserController).Get":    "获取单个用户\n测试用 id 可以是 1,2,3,4 试试换成不同的值看看",
+		"main.(*UserController).List":   "用户列表",
+		"main.Hello":                    "通过使用gin.Context 可以突破rpc风格上的使用限制",
+		"main.UserController":           "用户管理",
+	})
+}
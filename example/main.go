@@ -1,3 +1,5 @@
+//go:generate go run github.com/parkingwang/igo/cmd/igodoc
+
 package main
 
 import (
@@ -12,6 +14,7 @@ import (
 	"github.com/parkingwang/igo"
 	"github.com/parkingwang/igo/pkg/http/code"
 	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/cache"
 )
 
 // Something 你好
@@ -37,6 +40,8 @@ func main() {
 			return &Something{Value: "nihao"}
 		},
 	)
+	// 控制器 依赖同样由fx注入
+	app.ProvideController(NewUserController)
 
 	app.Run(
 		// 简单的定时器服务示例
@@ -47,9 +52,10 @@ func main() {
 		},
 
 		// web服务
-		func() igo.Servicer {
+		func(cs igo.Controllers) igo.Servicer {
 			srv := app.CreateWebServer()
 			initRoutes(srv)
+			cs.Register(srv.Router())
 			return srv
 		},
 	)
@@ -60,19 +66,29 @@ func initRoutes(srv *web.Server) {
 	r := srv.Router()
 
 	r.Get("/", Hello)
-	user := r.Group("/user")
-	user.Comment("user object")
-	user.Get("/", middleGinHandler, ListUser)
-	user.Get("/:id", web.CustomBindRequest(func(c *gin.Context) *UserIDReq {
+}
+
+// UserController 用户管理
+type UserController struct {
+	s *Something
+}
+
+func NewUserController(s *Something) *UserController {
+	return &UserController{s: s}
+}
+
+// Routes 自行注册路由 未实现时按方法名注册 如 List -> GET /user/
+func (uc *UserController) Routes(r web.Router) {
+	// 缓存用户列表 新增用户后失效
+	r.Get("/", middleGinHandler, cache.New(userCache, cache.Config{TTL: time.Minute, Tags: []string{"users"}}), uc.List)
+	r.Get("/:id", web.CustomBindRequest(func(c *gin.Context) *UserIDReq {
 		req := &UserIDReq{
 			Comment: "重写请求",
 		}
 		req.Content.ID = 1
 		return req
-	}), GetUser).Comment("测试用 id 可以是 1,2,3,4 试试换成不同的值看看")
-
-	user.Post("/:id/add", CreateUser)
-
+	}), uc.Get)
+	r.Post("/:id/add", uc.Create)
 }
 
 // UserInfo 用户信息
@@ -94,7 +110,8 @@ type UserInfoListRequest struct {
 	Keyword  string `form:"keyword" comment:"按指定关键字查询"`
 }
 
-func ListUser(ctx context.Context, in *UserInfoListRequest) (*UserInfoListResponse, error) {
+// List 用户列表
+func (uc *UserController) List(ctx context.Context, in *UserInfoListRequest) (*UserInfoListResponse, error) {
 	slog.InfoContext(ctx, "get users", "count", len(userlist))
 	if v := ctx.Value("value"); v != nil {
 		slog.InfoContext(ctx, "get middle value", "value", v)
@@ -118,8 +135,9 @@ type UserIDReq struct {
 	Comment string `form:"comment"`
 }
 
-// GetUser 获取单个用户
-func GetUser(ctx context.Context, in *UserIDReq) (*UserInfo, error) {
+// Get 获取单个用户
+// 测试用 id 可以是 1,2,3,4 试试换成不同的值看看
+func (uc *UserController) Get(ctx context.Context, in *UserIDReq) (*UserInfo, error) {
 	for _, user := range userlist {
 		if user.ID == in.ID {
 			return &user, nil
@@ -139,7 +157,13 @@ func Hello(ctx context.Context, in *web.Empty) error {
 	return nil
 }
 
-func CreateUser(ctx context.Context, in *UserInfo) (*UserInfo, error) {
+var userCache = cache.Local(100)
+
+// Create 新增用户
+func (uc *UserController) Create(ctx context.Context, in *UserInfo) (*UserInfo, error) {
+	if err := userCache.Invalidate(ctx, "users"); err != nil {
+		return nil, err
+	}
 	return in, nil
 }
 
@@ -0,0 +1,48 @@
+// Package panics 捕获panic的堆栈 记录到日志和trace
+package panics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Error recover捕获的panic
+type Error struct {
+	Value any
+	Stack []byte
+}
+
+// New 在recover处调用 保存panic时的堆栈
+func New(v any) *Error {
+	return &Error{Value: v, Stack: debug.Stack()}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap panic(err)时返回原始错误
+func (e *Error) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Record 输出错误日志 并以exception事件记录到span
+func Record(ctx context.Context, msg string, e *Error, attrs ...slog.Attr) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(e, trace.WithAttributes(
+		attribute.String("exception.stacktrace", string(e.Stack)),
+	))
+	span.SetStatus(codes.Error, e.Error())
+	attrs = append(attrs,
+		slog.Any("err", e.Value),
+		slog.String("stack", string(e.Stack)),
+	)
+	slog.LogAttrs(ctx, slog.LevelError, msg, attrs...)
+}
@@ -0,0 +1,69 @@
+package panics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestError(t *testing.T) {
+	e := New(io.EOF)
+	if !errors.Is(e, io.EOF) || e.Error() != "panic: EOF" {
+		t.Fatalf("unexpected %v", e)
+	}
+	if New("boom").Unwrap() != nil {
+		t.Fatal("expect nil for non error value")
+	}
+	if !bytes.Contains(e.Stack, []byte("TestError")) {
+		t.Fatalf("stack should contain caller: %s", e.Stack)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(old)
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test")
+	func() {
+		defer func() {
+			Record(ctx, "test.panic", New(recover()), slog.String("queue", "q1"))
+		}()
+		panic("boom")
+	}()
+	span.End()
+
+	log := buf.String()
+	for _, s := range []string{"level=ERROR", "msg=test.panic", "queue=q1", "err=boom", "stack="} {
+		if !strings.Contains(log, s) {
+			t.Errorf("log should contain %s: %s", s, log)
+		}
+	}
+	spans := sr.Ended()
+	if len(spans) != 1 || len(spans[0].Events()) != 1 {
+		t.Fatalf("expect exception event %v", spans)
+	}
+	ev := spans[0].Events()[0]
+	if ev.Name != "exception" {
+		t.Fatalf("unexpected event %s", ev.Name)
+	}
+	var stack bool
+	for _, attr := range ev.Attributes {
+		if attr.Key == "exception.stacktrace" && strings.Contains(attr.Value.AsString(), "TestRecord") {
+			stack = true
+		}
+	}
+	if !stack || spans[0].Status().Description != "panic: boom" {
+		t.Fatalf("expect stacktrace and error status %v %v", ev.Attributes, spans[0].Status())
+	}
+}
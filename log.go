@@ -6,6 +6,7 @@ import (
 
 	"log/slog"
 
+	"github.com/parkingwang/igo/pkg/requestid"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -70,9 +71,21 @@ func (h *logTraceHandle) Handle(c context.Context, r slog.Record) error {
 	if id := GetTraceID(c); id != "" {
 		r.AddAttrs(slog.String("traceid", id))
 	}
+	if id := requestid.FromContext(c); id != "" {
+		r.AddAttrs(slog.String("requestid", id))
+	}
 	return h.TextHandler.Handle(c, r)
 }
 
+// WithAttrs 保证slog.With之后仍然输出traceid/requestid
+func (h *logTraceHandle) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logTraceHandle{h.TextHandler.WithAttrs(attrs).(*slog.TextHandler)}
+}
+
+func (h *logTraceHandle) WithGroup(name string) slog.Handler {
+	return &logTraceHandle{h.TextHandler.WithGroup(name).(*slog.TextHandler)}
+}
+
 func GetTraceID(c context.Context) string {
 	span := trace.SpanContextFromContext(c)
 	if span.IsValid() {
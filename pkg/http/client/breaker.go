@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sony/gobreaker/v2"
+)
+
+// ServerError 5xx响应 熔断器按失败统计 Settings.IsSuccessful可以据此判断
+type ServerError struct {
+	StatusCode int
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("server error %d", e.StatusCode)
+}
+
+// BreakerTransport 带熔断的http.RoundTripper 网络错误和5xx响应按失败统计
+// 调用方取消的请求不代表后端故障 不计为失败
+// 熔断时返回 gobreaker.ErrOpenState 或 gobreaker.ErrTooManyRequests
+type BreakerTransport struct {
+	cb   *gobreaker.CircuitBreaker[*http.Response]
+	next http.RoundTripper
+}
+
+// NewBreakerTransport next为nil时使用http.DefaultTransport
+func NewBreakerTransport(s gobreaker.Settings, next http.RoundTripper) *BreakerTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	isSuccessful := s.IsSuccessful
+	if isSuccessful == nil {
+		isSuccessful = func(err error) bool { return err == nil }
+	}
+	s.IsSuccessful = func(err error) bool {
+		return errors.Is(err, context.Canceled) || isSuccessful(err)
+	}
+	return &BreakerTransport{
+		cb:   gobreaker.NewCircuitBreaker[*http.Response](s),
+		next: next,
+	}
+}
+
+func (t *BreakerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := t.cb.Execute(func() (*http.Response, error) {
+		res, err := t.next.RoundTrip(r)
+		if err == nil && res.StatusCode >= http.StatusInternalServerError {
+			return res, &ServerError{StatusCode: res.StatusCode}
+		}
+		return res, err
+	})
+	// 5xx仍然返回原始响应
+	var se *ServerError
+	if errors.As(err, &se) {
+		return res, nil
+	}
+	return res, err
+}
+
+// State 熔断器当前的状态
+func (t *BreakerTransport) State() gobreaker.State {
+	return t.cb.State()
+}
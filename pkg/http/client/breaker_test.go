@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sony/gobreaker/v2"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestBreakerIgnoreCanceled(t *testing.T) {
+	bt := NewBreakerTransport(gobreaker.Settings{
+		ReadyToTrip: func(c gobreaker.Counts) bool { return c.ConsecutiveFailures >= 1 },
+	}, roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, r.Context().Err()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1/", nil)
+	if _, err := bt.RoundTrip(r); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect canceled, got %v", err)
+	}
+	if bt.State() != gobreaker.StateClosed {
+		t.Fatal("canceled request should not trip the breaker")
+	}
+	// 超时仍然按失败统计
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	bt.RoundTrip(r.WithContext(ctx))
+	if bt.State() != gobreaker.StateOpen {
+		t.Fatal("expect breaker open after failure")
+	}
+}
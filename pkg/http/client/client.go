@@ -10,13 +10,17 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/parkingwang/igo/pkg/requestid"
 	"github.com/sony/gobreaker/v2"
 )
 
+const headerRequestTimeout = "X-Request-Timeout"
+
 type Client struct {
 	opt Option
 	// 熔断器
@@ -72,6 +76,16 @@ func (c *Client) Do(r *http.Request, out any) error {
 	if c.opt.ModfityRequest != nil {
 		c.opt.ModfityRequest(r)
 	}
+	// 将剩余的超时时间传递给下游 web服务会据此缩短超时时间
+	if deadline, ok := r.Context().Deadline(); ok && r.Header.Get(headerRequestTimeout) == "" {
+		if remain := time.Until(deadline).Milliseconds(); remain > 0 {
+			r.Header.Set(headerRequestTimeout, strconv.FormatInt(remain, 10))
+		}
+	}
+	// 传递请求ID 便于关联上下游的日志
+	if id := requestid.FromContext(r.Context()); id != "" && r.Header.Get(requestid.Header) == "" {
+		r.Header.Set(requestid.Header, id)
+	}
 	var err error
 	var response *http.Response
 	var start = time.Now()
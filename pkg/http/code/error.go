@@ -60,3 +60,11 @@ func NewNotfoundError(v any) error {
 		fmt.Sprintf("%v", v),
 	}
 }
+
+// NewTooManyRequestsError 请求过于频繁 触发限流
+func NewTooManyRequestsError(v any) error {
+	return &CodeError{
+		http.StatusTooManyRequests,
+		fmt.Sprintf("%v", v),
+	}
+}
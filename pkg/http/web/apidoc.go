@@ -2,9 +2,11 @@ package web
 
 import (
 	_ "embed"
+	"fmt"
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin/binding"
 	"github.com/parkingwang/igo/pkg/http/web/oas"
@@ -13,28 +15,49 @@ import (
 //go:embed oas/swagger-ui.html
 var swaggerUIData []byte
 
-func (r *Routes) ToDoc(info oas.DocInfo) (*oas.Spec, error) {
+// ToDoc 生成文档 指定version时只包含此版本的路由
+func (r *Routes) ToDoc(info oas.DocInfo, version ...string) (*oas.Spec, error) {
+	var v string
+	if len(version) > 0 {
+		v = version[0]
+	}
+	return r.toDoc(info, defaultOption(), v)
+}
+
+func (r *Routes) toDoc(info oas.DocInfo, opt *option, version string) (*oas.Spec, error) {
 
 	spec := oas.NewSpec()
 	spec.Info = info
+	if version != "" {
+		spec.Info.Version = version
+	}
 
 	// spec.Components.Schema["responseError"] = oas.Generate(reflect.ValueOf(nil))
 
 	paths := make(map[string]map[string]any)
 	for _, route := range *r {
+		if version != "" && route.version != version {
+			continue
+		}
 		if route.isDir {
 			spec.Tags = append(spec.Tags, oas.Tag{
 				Name:        strings.TrimLeft(route.basePath, "/"),
 				Description: route.comment,
 			})
 			for _, ru := range route.children {
-				toConveterRequest(paths, *ru)
+				toConveterRequest(paths, *ru, opt)
 			}
 		} else {
-			toConveterRequest(paths, *route)
+			toConveterRequest(paths, *route, opt)
 		}
 	}
 	spec.Paths = paths
+	if len(opt.authenticators) > 0 {
+		spec.Components.SecuritySchemes = make(map[string]oas.SecurityScheme)
+		for name, a := range opt.authenticators {
+			spec.Components.SecuritySchemes[name] = a.SecurityScheme()
+		}
+	}
 	return spec, nil
 }
 
@@ -46,7 +69,10 @@ var contentTypes = map[string]string{
 
 var reqTypeEmpty = reflect.TypeOf(Empty{})
 
-func toConveterRequest(root map[string]map[string]any, route routeInfo) {
+func toConveterRequest(root map[string]map[string]any, route routeInfo, opt *option) {
+	if route.proxy || route.static {
+		return
+	}
 	// 将gin的 :xx 替换为openapi的 {xx}
 	path := route.basePath + route.path
 	ps := strings.Split(path, "/")
@@ -70,15 +96,50 @@ func toConveterRequest(root map[string]map[string]any, route routeInfo) {
 	rp := oas.Request{
 		Tags: tags,
 		RequestComment: oas.RequestComment{
-			Summary: route.comment,
+			Summary:     route.comment,
+			Description: route.description,
 		},
 		OperationID: createOperationID(route),
 		Responses:   map[string]oas.Body{"200": {Description: "Successful operation"}},
 	}
 
+	for _, name := range route.authSchemes() {
+		rp.Security = append(rp.Security, map[string][]string{name: {}})
+	}
+	if len(rp.Security) > 0 {
+		rp.Responses["401"] = oas.Body{Description: "Unauthorized"}
+	}
+	if perms := route.permissions(); len(perms) > 0 {
+		rp.Permissions = perms
+		rp.Description = strings.TrimSpace(rp.Description + "\n\n需要权限: " + strings.Join(perms, ", "))
+		rp.Responses["403"] = oas.Body{Description: "Forbidden"}
+	}
+	if len(route.rateLimits()) > 0 {
+		rp.Responses["429"] = oas.Body{Description: "Too Many Requests"}
+	}
+	if route.timeoutValue() > 0 || opt.timeout.Handler > 0 {
+		rp.Responses["504"] = oas.Body{Description: "Gateway Timeout"}
+	}
+
+	if _, ok := route.deprecation(); ok {
+		rp.Deprecated = true
+	}
+	if t := route.sunsetValue(); !t.IsZero() {
+		rp.Description = strings.TrimSpace(rp.Description + "\n\n下线时间: " + t.Format(time.DateOnly))
+	}
+
+	if route.websocket {
+		rp.Description = strings.TrimSpace("WebSocket\n\n" + rp.Description)
+		rp.Responses["101"] = oas.Body{Description: "Switching Protocols"}
+		delete(rp.Responses, "200")
+		w[strings.ToLower(route.method)] = rp
+		root[path] = w
+		return
+	}
+
 	tp := route.funType.Type()
 
-	switch tp.Kind() {
+	switch tp.In(1).Kind() {
 	case reflect.Ptr:
 		in := tp.In(1).Elem()
 		// 请求参数跳过web.Empty对象
@@ -94,11 +155,22 @@ func toConveterRequest(root map[string]map[string]any, route routeInfo) {
 				}
 
 				for _, tag := range bodytypes {
-					reqbody := oas.Generate(reflect.New(in), tag)
+					structTag := tag
+					if tag == "form-data" {
+						structTag = "form"
+					}
+					reqbody := oas.Generate(reflect.New(in), structTag)
 					for _, v := range parameters {
 						delete(reqbody["schema"].Properties, v.Name)
 					}
-					body.Content[contentTypes[tag]] = reqbody
+					if tag == "json" {
+						// json请求体同样可以使用其他已注册的格式提交
+						for _, mt := range mediaTypes(opt, reflect.PointerTo(in)) {
+							body.Content[mt] = reqbody
+						}
+					} else {
+						body.Content[contentTypes[tag]] = reqbody
+					}
 				}
 				rp.RequestBody = body
 			}
@@ -108,18 +180,67 @@ func toConveterRequest(root map[string]map[string]any, route routeInfo) {
 			Required: true,
 			Content:  make(map[string]map[string]oas.Schema),
 		}
-		body.Content[contentTypes["json"]] = oas.Generate(reflect.New(tp.In(1)), "")
+		reqbody := oas.Generate(reflect.New(tp.In(1)), "json")
+		for _, mt := range mediaTypes(opt, tp.In(1)) {
+			body.Content[mt] = reqbody
+		}
 		rp.RequestBody = body
 	}
 
+	if rp.RequestBody != nil {
+		docBodyLimit(&rp, route, opt)
+	}
+
 	if tp.NumOut() == 2 {
-		out := tp.Out(0).Elem()
+		// 指针和chan使用元素的类型 其他如string直接使用
+		out := tp.Out(0)
+		if out.Kind() == reflect.Pointer || out.Kind() == reflect.Chan {
+			out = out.Elem()
+		}
 		const responseTag = "json"
-		rp.Responses["200"] = oas.Body{
-			Description: "Successful operation",
-			Content: map[string]map[string]oas.Schema{
-				contentTypes[responseTag]: oas.Generate(reflect.New(out), responseTag),
-			},
+		if tp.Out(0) == rtypeFileResponse {
+			rp.Responses["200"] = oas.Body{
+				Description: "File download",
+				// 文件的类型由内容决定
+				Content: map[string]map[string]oas.Schema{
+					"*/*": oas.BinarySchema(),
+				},
+			}
+		} else if isStreamType(tp.Out(0)) {
+			// SSE推送 chan元素为每条消息data的结构
+			schema := oas.Generate(reflect.New(out), responseTag)
+			if out == rtypeEvent {
+				schema = map[string]oas.Schema{"schema": {Type: "string"}}
+			}
+			rp.Parameters = append(rp.Parameters, oas.Parameter{
+				Name:        headerLastEventID,
+				In:          "header",
+				Description: "断线重连时最后收到的消息id",
+				Schema:      oas.Schema{Type: "string"},
+			})
+			rp.Responses["200"] = oas.Body{
+				Description: "Server-Sent Events stream",
+				Content: map[string]map[string]oas.Schema{
+					mimeEventStream: schema,
+				},
+			}
+		} else {
+			schema := oas.Generate(reflect.New(out), responseTag)
+			if opt.docEnvelope != nil {
+				schema["schema"] = opt.docEnvelope(schema["schema"])
+			}
+			outType := tp.Out(0)
+			if opt.docEnvelope != nil {
+				outType = reflect.TypeOf(map[string]any{})
+			}
+			content := make(map[string]map[string]oas.Schema)
+			for _, mt := range mediaTypes(opt, outType) {
+				content[mt] = schema
+			}
+			rp.Responses["200"] = oas.Body{
+				Description: "Successful operation",
+				Content:     content,
+			}
 		}
 	}
 
@@ -127,6 +248,15 @@ func toConveterRequest(root map[string]map[string]any, route routeInfo) {
 	root[path] = w
 }
 
+// mediaTypes 支持此类型数据的所有媒体类型
+func mediaTypes(opt *option, t reflect.Type) []string {
+	var list []string
+	for _, c := range opt.codecs.forType(t) {
+		list = append(list, c.MediaTypes()[0])
+	}
+	return list
+}
+
 func toConveterParameters(route routeInfo, in reflect.Type) ([]oas.Parameter, []string) {
 	bodyType := map[string]struct{}{}
 	var list []oas.Parameter
@@ -192,11 +322,18 @@ func toConveterParameters(route routeInfo, in reflect.Type) ([]oas.Parameter, []
 			if route.method != http.MethodGet {
 				if item.In == "" {
 					bodyType["form"] = struct{}{}
+					if field.Type == rtypeFile || field.Type == reflect.TypeOf([]File{}) {
+						bodyType["form-data"] = struct{}{}
+					}
 				}
 			}
 		}
 
 	}
+	// 上传文件只能使用multipart/form-data
+	if _, ok := bodyType["form-data"]; ok {
+		delete(bodyType, "form")
+	}
 	bodyTypes := []string{}
 	for k := range bodyType {
 		bodyTypes = append(bodyTypes, k)
@@ -215,3 +352,34 @@ func createOperationID(r routeInfo) string {
 	}
 	return strings.ToLower(r.method) + strings.Join(ps, "") + "OperationId"
 }
+
+// docBodyLimit 在请求体的描述中说明大小限制
+func docBodyLimit(rp *oas.Request, route routeInfo, opt *option) {
+	var limits []string
+	if n := route.bodyLimitValue(opt); n > 0 {
+		rp.MaxBodySize = n
+		limits = append(limits, "最大 "+formatBytes(n))
+	}
+	if _, ok := rp.RequestBody.Content[binding.MIMEJSON]; ok {
+		if d := opt.bodyLimit.MaxJSONDepth; d > 0 {
+			limits = append(limits, fmt.Sprintf("json最大深度 %d", d))
+		}
+		if n := opt.bodyLimit.MaxJSONElements; n > 0 {
+			limits = append(limits, fmt.Sprintf("数组/对象最多 %d 个元素", n))
+		}
+	}
+	if len(limits) > 0 {
+		rp.RequestBody.Description = strings.Join(limits, ", ")
+		rp.Responses["413"] = oas.Body{Description: "Payload Too Large"}
+	}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,157 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+)
+
+// Authenticator 身份认证
+// 内置的实现见 pkg/http/web/auth
+type Authenticator interface {
+	// Authenticate 认证成功后返回携带身份信息的context
+	Authenticate(r *http.Request) (context.Context, error)
+	// SecurityScheme 文档中的认证方式
+	SecurityScheme() oas.SecurityScheme
+}
+
+// WithAuthenticator 注册认证方式 路由通过Auth(name)声明使用
+func WithAuthenticator(name string, a Authenticator) Option {
+	return func(o *option) {
+		o.authenticators[name] = a
+	}
+}
+
+// Authorizer 权限验证
+// 内置基于角色的实现见 auth.NewRBAC
+type Authorizer interface {
+	// Authorize 验证当前请求是否拥有全部权限 ctx中包含认证后的身份信息
+	// 返回 *code.CodeError 时原样输出 其他错误按403输出
+	Authorize(ctx context.Context, perms []string) error
+}
+
+// AuthorizerFunc 使用函数实现Authorizer
+type AuthorizerFunc func(ctx context.Context, perms []string) error
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, perms []string) error {
+	return f(ctx, perms)
+}
+
+// WithAuthorizer 注册权限验证 路由通过Require声明需要的权限
+func WithAuthorizer(a Authorizer) Option {
+	return func(o *option) {
+		o.authorizer = a
+	}
+}
+
+const guardKey = "_igo_guard"
+
+// guardResult 记录guard的结果 同一个请求只执行一次
+type guardResult struct {
+	err error
+}
+
+// Guard 在gin中间件中提前执行路由声明的认证 限流 权限验证
+// 幂等/缓存等依赖身份信息的中间件需要先调用 rpc方法不会重复执行
+func Guard(c *gin.Context) error {
+	opt, ok := optionFrom(c)
+	if !ok {
+		return nil
+	}
+	return guard(c, opt, routeFrom(c, opt))
+}
+
+// guard 依次执行路由声明的认证 限流 权限验证 在参数绑定之前执行
+func guard(c *gin.Context, opt *option, info *routeInfo) error {
+	if info == nil {
+		return nil
+	}
+	if v, ok := c.Get(guardKey); ok {
+		return v.(guardResult).err
+	}
+	err := authenticate(c, opt, info.authSchemes())
+	if err == nil {
+		err = rateLimit(c, opt, info.rateLimits())
+	}
+	if err == nil {
+		err = authorize(c, opt, info.permissions())
+	}
+	c.Set(guardKey, guardResult{err})
+	return err
+}
+
+// authorize 验证路由声明的权限 在参数绑定之前执行
+func authorize(c *gin.Context, opt *option, perms []string) error {
+	if len(perms) == 0 {
+		return nil
+	}
+	if opt.authorizer == nil {
+		return errors.New("authorizer not register")
+	}
+	err := opt.authorizer.Authorize(c, perms)
+	if err == nil {
+		return nil
+	}
+	var ce *code.CodeError
+	if errors.As(err, &ce) {
+		return err
+	}
+	return code.NewForbiddenError(err)
+}
+
+// authenticate 依次尝试路由声明的认证方式 成功后将身份信息写入请求的context
+func authenticate(c *gin.Context, opt *option, schemes []string) error {
+	if len(schemes) == 0 {
+		return nil
+	}
+	var lastErr error
+	for _, name := range schemes {
+		a, ok := opt.authenticators[name]
+		if !ok {
+			return fmt.Errorf("authenticator %s not register", name)
+		}
+		ctx, err := a.Authenticate(c.Request)
+		if err == nil {
+			c.Request = c.Request.WithContext(ctx)
+			return nil
+		}
+		lastErr = err
+	}
+	return code.NewUnauthorizedError(lastErr)
+}
+
+// checkRoutes 启动时检查路由声明的认证方式和限流策略是否都已注册
+func (r *Routes) checkRoutes(opt *option) error {
+	check := func(info *routeInfo) error {
+		if len(info.requires) > 0 && opt.authorizer == nil {
+			return fmt.Errorf("route %s%s: require permissions but authorizer not register", info.basePath, info.path)
+		}
+		for _, name := range info.auth {
+			if _, ok := opt.authenticators[name]; !ok {
+				return fmt.Errorf("route %s%s: authenticator %s not register", info.basePath, info.path, name)
+			}
+		}
+		for _, name := range info.limits {
+			if _, ok := opt.rateLimiters[name]; !ok {
+				return fmt.Errorf("route %s%s: rate limiter %s not register", info.basePath, info.path, name)
+			}
+		}
+		return nil
+	}
+	for _, v := range *r {
+		if err := check(v); err != nil {
+			return err
+		}
+		for _, child := range v.children {
+			if err := check(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
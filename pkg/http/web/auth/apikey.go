@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+)
+
+// APIKeyConfig apikey认证配置
+type APIKeyConfig struct {
+	// 读取key的header 默认 X-API-Key
+	Header string
+	// 读取key的query参数 为空则不从query读取
+	Query string
+	// ID为身份标识 Secret为key
+	Keys []Credential
+}
+
+// APIKey 固定密钥认证 适合内部服务之间调用
+type APIKey struct {
+	cfg APIKeyConfig
+}
+
+func NewAPIKey(cfg APIKeyConfig) (*APIKey, error) {
+	if cfg.Header == "" {
+		cfg.Header = "X-API-Key"
+	}
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("auth: apikey keys empty")
+	}
+	return &APIKey{cfg: cfg}, nil
+}
+
+func (a *APIKey) Authenticate(r *http.Request) (context.Context, error) {
+	key := r.Header.Get(a.cfg.Header)
+	if key == "" && a.cfg.Query != "" {
+		key = r.URL.Query().Get(a.cfg.Query)
+	}
+	if key == "" {
+		return nil, errors.New("missing api key")
+	}
+	var matched *Credential
+	// 遍历全部 避免通过耗时推测key
+	for i, c := range a.cfg.Keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(c.Secret)) == 1 {
+			matched = &a.cfg.Keys[i]
+		}
+	}
+	if matched == nil {
+		return nil, errors.New("invalid api key")
+	}
+	return NewContext(r.Context(), &Principal{
+		Subject: matched.ID,
+		Scheme:  SchemeAPIKey,
+		Roles:   matched.Roles,
+	}), nil
+}
+
+func (a *APIKey) SecurityScheme() oas.SecurityScheme {
+	return oas.SecurityScheme{
+		Type: "apiKey",
+		In:   "header",
+		Name: a.cfg.Header,
+	}
+}
@@ -0,0 +1,164 @@
+// Package auth 内置的身份认证 实现了 web.Authenticator
+//
+//	srv := web.New(
+//		web.WithAuthenticator("jwt", auth.MustJWT(auth.JWTConfig{Secret: "..."})),
+//	)
+//	srv.Router().Get("/me", me).Auth("jwt")
+//
+//	func me(ctx context.Context, in *web.Empty) (*User, error) {
+//		p, _ := auth.FromContext(ctx)
+//		...
+//	}
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+)
+
+const (
+	SchemeJWT    = "jwt"
+	SchemeAPIKey = "apikey"
+	SchemeHMAC   = "hmac"
+	SchemeMTLS   = "mtls"
+)
+
+// Principal 通过认证的身份信息
+type Principal struct {
+	// 身份标识 如用户id/accesskey
+	Subject string
+	// 认证方式 jwt/apikey/hmac/mtls
+	Scheme string
+	// 角色 用于权限验证
+	Roles []string
+	// 原始的声明信息 jwt为claims
+	Claims map[string]any
+}
+
+type principalKey struct{}
+
+// NewContext 将身份信息写入context
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 获取rpc方法context中的身份信息
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// WithPrincipal rpc方法可以直接声明 *auth.Principal 参数 未认证时返回401
+//
+//	r.Get("/me", func(ctx context.Context, in *web.Empty, p *auth.Principal) (*User, error) {...}).Auth("jwt")
+func WithPrincipal() web.Option {
+	return web.WithProvider(func(ctx context.Context) (*Principal, error) {
+		p, ok := FromContext(ctx)
+		if !ok {
+			return nil, code.NewUnauthorizedError("unauthenticated")
+		}
+		return p, nil
+	})
+}
+
+// Claim 获取声明信息中指定类型的值
+func Claim[T any](ctx context.Context, key string) (T, bool) {
+	var zero T
+	p, ok := FromContext(ctx)
+	if !ok {
+		return zero, false
+	}
+	v, ok := p.Claims[key].(T)
+	return v, ok
+}
+
+// Credential 认证凭据
+type Credential struct {
+	// apikey为身份标识 hmac为accesskey
+	ID     string
+	Secret string
+	Roles  []string
+}
+
+// Config 用于从配置文件创建认证方式
+type Config struct {
+	// jwt apikey hmac mtls
+	Type      string
+	JWTConfig `mapstructure:",squash"`
+	// apikey: 读取key的header 默认 X-API-Key
+	Header string
+	// apikey: 读取key的query参数 为空则不从query读取
+	Query string
+	// apikey/hmac 的凭据
+	Credentials []Credential
+	// hmac: 允许的时间误差 默认5分钟
+	MaxSkew time.Duration
+}
+
+// New 根据配置创建认证方式
+func New(cfg Config) (web.Authenticator, error) {
+	switch cfg.Type {
+	case SchemeJWT:
+		return NewJWT(cfg.JWTConfig)
+	case SchemeAPIKey:
+		return NewAPIKey(APIKeyConfig{
+			Header: cfg.Header,
+			Query:  cfg.Query,
+			Keys:   cfg.Credentials,
+		})
+	case SchemeHMAC:
+		return NewHMAC(HMACConfig{
+			Credentials: cfg.Credentials,
+			MaxSkew:     cfg.MaxSkew,
+		})
+	case SchemeMTLS:
+		return NewMTLS(), nil
+	}
+	return nil, fmt.Errorf("auth: unknown type %q", cfg.Type)
+}
+
+// Middleware 用于gin风格的路由
+// 多个认证方式满足其一即可
+func Middleware(as ...web.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var lastErr error
+		for _, a := range as {
+			ctx, err := a.Authenticate(c.Request)
+			if err == nil {
+				c.Request = c.Request.WithContext(ctx)
+				c.Next()
+				return
+			}
+			lastErr = err
+		}
+		web.Abort(c, code.NewUnauthorizedError(lastErr))
+	}
+}
+
+var _ = []web.Authenticator{&JWT{}, &APIKey{}, &HMAC{}, &MTLS{}}
+
+// schemeHTTP 文档中的Bearer认证
+func schemeHTTP(format, desc string) oas.SecurityScheme {
+	return oas.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: format,
+		Description:  desc,
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(h) > len(prefix) && (h[:len(prefix)] == prefix || h[:len(prefix)] == "bearer ") {
+		return h[len(prefix):], true
+	}
+	return "", false
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/webtest"
+)
+
+func TestJWT(t *testing.T) {
+	j := MustJWT(JWTConfig{Secret: "secret", Issuer: "igo"})
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "1001",
+		"iss":   "igo",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"roles": []string{"admin"},
+	}).SignedString([]byte("secret"))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	ctx, err := j.Authenticate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := FromContext(ctx)
+	if !ok || p.Subject != "1001" || len(p.Roles) != 1 || p.Roles[0] != "admin" {
+		t.Fatalf("principal %+v", p)
+	}
+
+	// 错误的签名
+	r.Header.Set("Authorization", "Bearer "+token+"x")
+	if _, err := j.Authenticate(r); err == nil {
+		t.Fatal("expect signature error")
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	h, _ := NewHMAC(HMACConfig{Credentials: []Credential{{ID: "ak", Secret: "sk"}}})
+	r := httptest.NewRequest(http.MethodPost, "/pay?b=2&a=1", strings.NewReader(`{"amount":1}`))
+	if err := Sign(r, "ak", "sk"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := h.Authenticate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := FromContext(ctx); p.Subject != "ak" {
+		t.Fatalf("principal %+v", p)
+	}
+
+	// 篡改请求体
+	r.Body = http.NoBody
+	if _, err := h.Authenticate(r); err == nil {
+		t.Fatal("expect signature mismatch")
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	a, _ := NewAPIKey(APIKeyConfig{Query: "key", Keys: []Credential{{ID: "svc", Secret: "k1"}}})
+	r := httptest.NewRequest(http.MethodGet, "/?key=k1", nil)
+	if _, err := a.Authenticate(r); err != nil {
+		t.Fatal(err)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/?key=k2", nil)
+	if _, err := a.Authenticate(r); err == nil {
+		t.Fatal("expect invalid key")
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	a := NewRBAC(map[string][]string{
+		"admin":    {"*"},
+		"operator": {"user:read", "order:*"},
+	})
+	ctx := NewContext(context.Background(), &Principal{Roles: []string{"operator"}})
+	if err := a.Authorize(ctx, []string{"user:read", "order:refund"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Authorize(ctx, []string{"user:write"}); err == nil {
+		t.Fatal("expect permission denied")
+	}
+	if err := a.Authorize(context.Background(), []string{"user:read"}); err == nil {
+		t.Fatal("expect unauthenticated")
+	}
+}
+
+func TestNestedGroup(t *testing.T) {
+	a, _ := NewAPIKey(APIKeyConfig{Keys: []Credential{
+		{ID: "admin", Secret: "k1", Roles: []string{"admin"}},
+		{ID: "operator", Secret: "k2", Roles: []string{"operator"}},
+	}})
+	rbac := NewRBAC(map[string][]string{"admin": {"*"}})
+	srv := web.New(web.WithAuthenticator("apikey", a), web.WithAuthorizer(rbac))
+	admin := srv.Router().Group("/admin")
+	admin.Auth("apikey").Require("user:write")
+	users := admin.Group("/users")
+	users.Get("/:id", func(ctx context.Context, in *web.Empty) (string, error) {
+		return "ok", nil
+	})
+
+	get := func(key string) int {
+		r := httptest.NewRequest(http.MethodGet, "/admin/users/1", nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		srv.GinEngine().ServeHTTP(w, r)
+		return w.Code
+	}
+	// 认证和权限声明在上上级分组
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "k2": http.StatusForbidden, "k1": http.StatusOK} {
+		if code := get(key); code != want {
+			t.Errorf("key %q: expect %d, got %d", key, want, code)
+		}
+	}
+}
+
+type greeter struct{ prefix string }
+
+func TestWithPrincipal(t *testing.T) {
+	a, _ := NewAPIKey(APIKeyConfig{Keys: []Credential{{ID: "svc", Secret: "k1"}}})
+	s := webtest.New(t, func(r web.Router) {
+		r.Get("/me", func(ctx context.Context, in *web.Empty, p *Principal, g *greeter) (string, error) {
+			return g.prefix + p.Subject, nil
+		}).Auth("apikey")
+		r.Get("/anonymous", func(ctx context.Context, in *web.Empty, p *Principal) (string, error) {
+			return p.Subject, nil
+		})
+	}, web.WithAuthenticator("apikey", a), WithPrincipal(), web.WithValues(&greeter{"hi "})).ValidateOpenAPI()
+
+	s.Get("/me").WithHeader("X-API-Key", "k1").ExpectStatus(http.StatusOK).ExpectBodyContains(`"hi svc"`)
+	s.Get("/anonymous").ExpectStatus(http.StatusUnauthorized)
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+)
+
+const (
+	HeaderAccessKey = "X-Access-Key"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderSignature = "X-Signature"
+)
+
+// HMACConfig 请求签名认证配置
+type HMACConfig struct {
+	// ID为accesskey Secret为签名密钥
+	Credentials []Credential
+	// 允许的时间误差 默认5分钟
+	MaxSkew time.Duration
+}
+
+// HMAC 请求签名认证
+//
+// 签名内容为以下各项使用\n连接
+//
+//	METHOD
+//	PATH
+//	QUERY 按key排序后编码
+//	TIMESTAMP unix秒
+//	hex(sha256(BODY))
+//
+// X-Signature = hex(hmac_sha256(secret, 签名内容))
+type HMAC struct {
+	cfg     HMACConfig
+	secrets map[string]*Credential
+}
+
+func NewHMAC(cfg HMACConfig) (*HMAC, error) {
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = time.Minute * 5
+	}
+	if len(cfg.Credentials) == 0 {
+		return nil, errors.New("auth: hmac credentials empty")
+	}
+	h := &HMAC{cfg: cfg, secrets: make(map[string]*Credential)}
+	for i, c := range cfg.Credentials {
+		h.secrets[c.ID] = &cfg.Credentials[i]
+	}
+	return h, nil
+}
+
+func (h *HMAC) Authenticate(r *http.Request) (context.Context, error) {
+	ak := r.Header.Get(HeaderAccessKey)
+	c, ok := h.secrets[ak]
+	if !ok {
+		return nil, errors.New("invalid access key")
+	}
+	ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid timestamp")
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > h.cfg.MaxSkew || d < -h.cfg.MaxSkew {
+		return nil, errors.New("timestamp expired")
+	}
+	sign, err := signRequest(r, c.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(sign), []byte(r.Header.Get(HeaderSignature))) {
+		return nil, errors.New("signature mismatch")
+	}
+	return NewContext(r.Context(), &Principal{
+		Subject: c.ID,
+		Scheme:  SchemeHMAC,
+		Roles:   c.Roles,
+	}), nil
+}
+
+func (h *HMAC) SecurityScheme() oas.SecurityScheme {
+	return oas.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        HeaderSignature,
+		Description: "请求签名 同时需要 X-Access-Key 和 X-Timestamp",
+	}
+}
+
+// Sign 为请求添加签名 用于客户端
+// 可以在 client.Option.ModfityRequest 中调用
+func Sign(r *http.Request, accessKey, secret string) error {
+	r.Header.Set(HeaderAccessKey, accessKey)
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	sign, err := signRequest(r, secret)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(HeaderSignature, sign)
+	return nil
+}
+
+func signRequest(r *http.Request, secret string) (string, error) {
+	body := []byte{}
+	if r.Body != nil && r.Body != http.NoBody {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return "", err
+		}
+		r.Body.Close()
+		// 读取后放回 后续还需要绑定参数
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		r.URL.Query().Encode() + "\n" +
+		r.Header.Get(HeaderTimestamp) + "\n" +
+		hex.EncodeToString(bodyHash[:]),
+	))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
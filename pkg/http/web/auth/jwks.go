@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwks 从文件或url加载的公钥集合
+// url方式定期刷新 遇到未知的kid时也会尝试刷新
+type jwks struct {
+	url     string
+	file    string
+	refresh time.Duration
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+	// 同一时间只允许一个请求刷新
+	refreshing sync.Mutex
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (s *jwks) load(ctx context.Context) error {
+	var (
+		b   []byte
+		err error
+	)
+	if s.url != "" {
+		b, err = fetchJWKS(ctx, s.url)
+	} else {
+		b, err = os.ReadFile(s.file)
+	}
+	if err != nil {
+		return err
+	}
+	var set jwkSet
+	if err := json.Unmarshal(b, &set); err != nil {
+		return err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("kid %s: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+func fetchJWKS(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks status %d", res.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+func (s *jwks) lookup(kid string) (crypto.PublicKey, bool, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true, s.loadedAt
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok, s.loadedAt
+}
+
+func (s *jwks) keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok, loadedAt := s.lookup(kid)
+	if s.url != "" {
+		// 定期刷新 或者密钥轮换后出现新的kid 最多每分钟刷新一次
+		stale := time.Since(loadedAt) > s.refresh
+		if (stale || (!ok && time.Since(loadedAt) > time.Minute)) && s.refreshing.TryLock() {
+			if err := s.load(context.Background()); err != nil {
+				slog.Warn("auth: refresh jwks failed", slog.String("url", s.url), slog.String("err", err.Error()))
+			} else {
+				key, ok, _ = s.lookup(kid)
+			}
+			s.refreshing.Unlock()
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, errors.New("unsupported kty " + k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+)
+
+// JWTConfig jwt认证配置
+// 密钥来源优先级 JWKSURL > JWKSFile > PublicKeyFile > Secret
+type JWTConfig struct {
+	// 允许的签名算法 默认根据密钥类型决定
+	// HS256/HS384/HS512 RS256/RS384/RS512 ES256/ES384/ES512
+	Algorithms []string
+	// HS系列算法的密钥
+	Secret string
+	// RS/ES系列算法的PEM格式公钥文件
+	PublicKeyFile string
+	// JWKS文件
+	JWKSFile string
+	// JWKS地址
+	JWKSURL string
+	// JWKS刷新间隔 默认10分钟
+	JWKSRefresh time.Duration
+	// 验证iss 为空不验证
+	Issuer string
+	// 验证aud 为空不验证
+	Audience string
+	// 角色所在的claim 默认roles
+	RolesClaim string
+	// 允许的时间误差
+	Leeway time.Duration
+}
+
+// JWT 从 Authorization: Bearer <token> 中读取并验证jwt
+type JWT struct {
+	cfg    JWTConfig
+	parser *jwt.Parser
+	key    func(*jwt.Token) (any, error)
+}
+
+func NewJWT(cfg JWTConfig) (*JWT, error) {
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	j := &JWT{cfg: cfg}
+	var defaultAlgs []string
+	switch {
+	case cfg.JWKSURL != "" || cfg.JWKSFile != "":
+		ks := &jwks{url: cfg.JWKSURL, file: cfg.JWKSFile, refresh: cfg.JWKSRefresh}
+		if ks.refresh <= 0 {
+			ks.refresh = time.Minute * 10
+		}
+		if err := ks.load(context.Background()); err != nil {
+			return nil, fmt.Errorf("auth: load jwks %w", err)
+		}
+		j.key = ks.keyfunc
+		defaultAlgs = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+	case cfg.PublicKeyFile != "":
+		b, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: read public key %w", err)
+		}
+		key, alg, err := parsePublicKey(b)
+		if err != nil {
+			return nil, err
+		}
+		j.key = func(*jwt.Token) (any, error) { return key, nil }
+		defaultAlgs = []string{alg + "256", alg + "384", alg + "512"}
+	case cfg.Secret != "":
+		secret := []byte(cfg.Secret)
+		j.key = func(*jwt.Token) (any, error) { return secret, nil }
+		defaultAlgs = []string{"HS256", "HS384", "HS512"}
+	default:
+		return nil, errors.New("auth: jwt key not configured")
+	}
+	algs := cfg.Algorithms
+	if len(algs) == 0 {
+		algs = defaultAlgs
+	}
+	opts := []jwt.ParserOption{
+		// 必须限制算法 防止使用公钥作为HS密钥的攻击
+		jwt.WithValidMethods(algs),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	j.parser = jwt.NewParser(opts...)
+	return j, nil
+}
+
+// MustJWT 同NewJWT 失败时panic
+func MustJWT(cfg JWTConfig) *JWT {
+	j, err := NewJWT(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return j
+}
+
+func (j *JWT) Authenticate(r *http.Request) (context.Context, error) {
+	raw, ok := bearerToken(r)
+	if !ok {
+		return nil, errors.New("missing bearer token")
+	}
+	claims := jwt.MapClaims{}
+	if _, err := j.parser.ParseWithClaims(raw, claims, j.key); err != nil {
+		return nil, err
+	}
+	sub, _ := claims.GetSubject()
+	return NewContext(r.Context(), &Principal{
+		Subject: sub,
+		Scheme:  SchemeJWT,
+		Roles:   claimStrings(claims[j.cfg.RolesClaim]),
+		Claims:  claims,
+	}), nil
+}
+
+func (j *JWT) SecurityScheme() oas.SecurityScheme {
+	return schemeHTTP("JWT", "Authorization: Bearer <jwt>")
+}
+
+// claimStrings 兼容 "a b" / ["a","b"] 两种格式
+func claimStrings(v any) []string {
+	switch x := v.(type) {
+	case string:
+		return strings.Fields(x)
+	case []any:
+		list := make([]string, 0, len(x))
+		for _, item := range x {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func parsePublicKey(b []byte) (crypto.PublicKey, string, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(b); err == nil {
+		return key, "RS", nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(b); err == nil {
+		return key, "ES", nil
+	}
+	return nil, "", errors.New("auth: unsupported public key, must be RSA or EC PEM")
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+)
+
+// MTLS 使用mTLS验证通过的客户端证书作为身份
+// 需要配置 server.web.tls.clientCAFile
+// Subject为证书的CommonName 角色为OrganizationalUnit
+type MTLS struct{}
+
+func NewMTLS() *MTLS {
+	return &MTLS{}
+}
+
+func (m *MTLS) Authenticate(r *http.Request) (context.Context, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, errors.New("missing verified client certificate")
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	return NewContext(r.Context(), &Principal{
+		Subject: cert.Subject.CommonName,
+		Scheme:  SchemeMTLS,
+		Roles:   cert.Subject.OrganizationalUnit,
+		Claims: map[string]any{
+			"serial": cert.SerialNumber.String(),
+			"dns":    cert.DNSNames,
+			"issuer": cert.Issuer.CommonName,
+		},
+	}), nil
+}
+
+func (m *MTLS) SecurityScheme() oas.SecurityScheme {
+	return oas.SecurityScheme{
+		Type:        "mutualTLS",
+		Description: "TLS client certificate",
+	}
+}
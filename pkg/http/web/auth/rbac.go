@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/parkingwang/igo/pkg/http/code"
+)
+
+// RBAC 基于角色的权限验证 实现了 web.Authorizer
+// 权限支持通配符 user:* 表示user下的所有权限 * 表示全部权限
+//
+//	auth.NewRBAC(map[string][]string{
+//		"admin":    {"*"},
+//		"operator": {"user:read", "order:*"},
+//	})
+type RBAC struct {
+	roles map[string][]string
+}
+
+func NewRBAC(table map[string][]string) *RBAC {
+	return &RBAC{roles: table}
+}
+
+func (a *RBAC) Authorize(ctx context.Context, perms []string) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return code.NewUnauthorizedError("unauthenticated")
+	}
+	for _, perm := range perms {
+		if !a.allow(p.Roles, perm) {
+			return fmt.Errorf("permission denied: %s", perm)
+		}
+	}
+	return nil
+}
+
+func (a *RBAC) allow(roles []string, perm string) bool {
+	for _, role := range roles {
+		for _, granted := range a.roles[role] {
+			if matchPermission(granted, perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchPermission(granted, perm string) bool {
+	if granted == "*" || granted == perm {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(granted, "*"); ok {
+		return strings.HasPrefix(perm, prefix)
+	}
+	return false
+}
@@ -35,7 +35,7 @@ func deepfindTags(t reflect.Type, m map[string]bool) {
 	}
 }
 
-func checkReqParam(ctx *gin.Context, obj any, tags map[string]bool) error {
+func checkReqParam(ctx *gin.Context, obj any, tags map[string]bool, codecs *codecSet) error {
 	if tags["header"] {
 		if err := ctx.ShouldBindHeader(obj); err != nil {
 			return err
@@ -54,6 +54,10 @@ func checkReqParam(ctx *gin.Context, obj any, tags map[string]bool) error {
 		if err := ctx.ShouldBindJSON(obj); err != nil {
 			return err
 		}
+	} else if c, ok := codecs.forRequest(ctx.ContentType()); ok {
+		if err := ctx.ShouldBindWith(obj, codecBinding{c}); err != nil {
+			return err
+		}
 	} else {
 		if err := ctx.ShouldBind(obj); err != nil {
 			return err
@@ -0,0 +1,175 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/parkingwang/igo/pkg/http/code"
+)
+
+// BodyLimitOption 请求体限制 仅对rpc方法生效
+type BodyLimitOption struct {
+	// 请求体最大字节数 默认32MB 小于0不限制 路由可以通过BodyLimit覆盖
+	MaxBytes int64
+	// multipart表单在内存中的最大字节数 超出的部分写入临时文件 默认32MB
+	MaxMultipartMemory int64
+	// json最大嵌套深度 默认100 小于0不限制
+	MaxJSONDepth int
+	// json单个数组或对象最多的元素个数 默认不限制
+	MaxJSONElements int
+}
+
+func defaultBodyLimitOption() BodyLimitOption {
+	return BodyLimitOption{
+		MaxBytes:           32 << 20,
+		MaxMultipartMemory: 32 << 20,
+		MaxJSONDepth:       100,
+	}
+}
+
+// WithBodyLimit 请求体限制 未设置的字段使用默认值
+func WithBodyLimit(b BodyLimitOption) Option {
+	return func(o *option) {
+		if b.MaxBytes != 0 {
+			o.bodyLimit.MaxBytes = b.MaxBytes
+		}
+		if b.MaxMultipartMemory > 0 {
+			o.bodyLimit.MaxMultipartMemory = b.MaxMultipartMemory
+		}
+		if b.MaxJSONDepth != 0 {
+			o.bodyLimit.MaxJSONDepth = b.MaxJSONDepth
+		}
+		if b.MaxJSONElements != 0 {
+			o.bodyLimit.MaxJSONElements = b.MaxJSONElements
+		}
+	}
+}
+
+// bodyLimitValue 路由生效的请求体大小限制 小于等于0表示不限制
+func (r *routeInfo) bodyLimitValue(opt *option) int64 {
+	for p := r; p != nil; p = p.parent {
+		if p.bodyLimit != 0 {
+			return p.bodyLimit
+		}
+	}
+	return opt.bodyLimit.MaxBytes
+}
+
+const bodyLimitKey = "_igo_body_limit"
+
+// bodyLimitHandler 在所有中间件之前限制rpc路由的请求体 幂等/日志等中间件读取的也是受限的请求体
+func bodyLimitHandler(opt *option) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := routeFrom(c, opt)
+		if info == nil {
+			return
+		}
+		if err := limitBody(c, opt, info); err != nil {
+			Abort(c, err)
+		}
+	}
+}
+
+// limitBody 限制读取的请求体大小 Content-Length超出时直接返回错误 同一个请求只执行一次
+func limitBody(c *gin.Context, opt *option, info *routeInfo) error {
+	if c.GetBool(bodyLimitKey) {
+		return nil
+	}
+	c.Set(bodyLimitKey, true)
+	n := info.bodyLimitValue(opt)
+	if n <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	if c.Request.ContentLength > n {
+		return bodyTooLargeError(n)
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, n)
+	return nil
+}
+
+func bodyTooLargeError(n int64) error {
+	return code.NewCodeError(http.StatusRequestEntityTooLarge, "request body too large, limit %d bytes", n)
+}
+
+// bindError 读取请求体超出限制时输出413 其他为400
+func bindError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return bodyTooLargeError(mbe.Limit)
+	}
+	var ce *code.CodeError
+	if errors.As(err, &ce) {
+		return err
+	}
+	return code.NewBadRequestError(err)
+}
+
+// checkJSONLimits 按内容类型检查json的深度和元素个数 检查后恢复请求体
+func checkJSONLimits(c *gin.Context, opt *option, defaultJSON bool) error {
+	limit := opt.bodyLimit
+	if limit.MaxJSONDepth <= 0 && limit.MaxJSONElements <= 0 {
+		return nil
+	}
+	ct := c.ContentType()
+	if !(ct == "" && defaultJSON) && ct != binding.MIMEJSON && !strings.HasSuffix(ct, "+json") {
+		return nil
+	}
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	b, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(b))
+	return scanJSON(b, limit.MaxJSONDepth, limit.MaxJSONElements)
+}
+
+// scanJSON 只扫描括号和逗号 语法错误由后续的解码处理 超出限制时统一输出413
+func scanJSON(b []byte, maxDepth, maxElements int) error {
+	var (
+		// 每一层已有的逗号个数
+		counts   []int
+		inString bool
+		escaped  bool
+	)
+	for _, ch := range b {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			counts = append(counts, 0)
+			if maxDepth > 0 && len(counts) > maxDepth {
+				return code.NewCodeError(http.StatusRequestEntityTooLarge, "json depth exceeds %d", maxDepth)
+			}
+		case '}', ']':
+			if len(counts) > 0 {
+				counts = counts[:len(counts)-1]
+			}
+		case ',':
+			if len(counts) > 0 {
+				counts[len(counts)-1]++
+				if maxElements > 0 && counts[len(counts)-1]+1 > maxElements {
+					return code.NewCodeError(http.StatusRequestEntityTooLarge, "json elements exceeds %d", maxElements)
+				}
+			}
+		}
+	}
+	return nil
+}
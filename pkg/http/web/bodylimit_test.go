@@ -0,0 +1,64 @@
+package web_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+	"github.com/parkingwang/igo/pkg/http/web/webtest"
+)
+
+type bodyLimitRequest struct {
+	Name string `json:"name"`
+	Tags []any  `json:"tags"`
+}
+
+type bodyLimitResponse struct {
+	Name string `json:"name"`
+}
+
+func TestBodyLimit(t *testing.T) {
+	create := func(ctx context.Context, in *bodyLimitRequest) (*bodyLimitResponse, error) {
+		return &bodyLimitResponse{Name: in.Name}, nil
+	}
+	s := webtest.New(t, func(r web.Router) {
+		r.Post("/user", create)
+		r.Post("/small", create).BodyLimit(16)
+		// 中间件读取的请求体同样受限制
+		r.Post("/middleware", func(c *gin.Context) {
+			if _, err := io.ReadAll(c.Request.Body); err != nil {
+				web.Abort(c, err)
+			}
+		}, create).BodyLimit(16)
+	}, web.WithBodyLimit(web.BodyLimitOption{MaxBytes: 1 << 10, MaxJSONDepth: 3, MaxJSONElements: 3}))
+
+	s.Post("/user").WithJSON(map[string]any{"name": "bob"}).ExpectStatus(http.StatusOK)
+	s.Post("/user").WithJSON(map[string]any{"name": strings.Repeat("x", 2<<10)}).
+		ExpectStatus(http.StatusRequestEntityTooLarge).ExpectBodyContains("limit 1024 bytes")
+	// 没有Content-Length时读取超出限制
+	s.Post("/user").WithBody("application/json", io.MultiReader(strings.NewReader(`{"name":"`+strings.Repeat("x", 2<<10)+`"}`))).
+		ExpectStatus(http.StatusRequestEntityTooLarge)
+	s.Post("/small").WithJSON(map[string]any{"name": "bobbobbob"}).ExpectStatus(http.StatusRequestEntityTooLarge)
+	s.Post("/middleware").WithBody("application/json", io.MultiReader(strings.NewReader(`{"name":"bobbobbob"}`))).
+		ExpectStatus(http.StatusRequestEntityTooLarge)
+
+	s.Post("/user").WithJSON(map[string]any{"tags": []any{[]any{[]any{1}}}}).
+		ExpectStatus(http.StatusRequestEntityTooLarge).ExpectBodyContains("json depth exceeds 3")
+	s.Post("/user").WithJSON(map[string]any{"tags": []any{1, 2, 3, 4}}).
+		ExpectStatus(http.StatusRequestEntityTooLarge).ExpectBodyContains("json elements exceeds 3")
+	s.Post("/user").WithJSON(map[string]any{"name": "a,b,c,d,[[[["}).ExpectStatus(http.StatusOK)
+
+	spec, err := s.Server().OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := spec.Paths["/small"]["post"].(oas.Request)
+	if op.MaxBodySize != 16 || op.Responses["413"].Description == "" || !strings.Contains(op.RequestBody.Description, "16B") {
+		t.Fatalf("unexpected doc %+v", op)
+	}
+}
@@ -0,0 +1,198 @@
+// Package cache GET请求的响应缓存 以及基于ETag/Last-Modified的条件请求
+//
+//	store := cache.Local(1000)
+//	srv.Router().Get("/users", cache.New(store, cache.Config{TTL: time.Minute, Tags: []string{"users"}}), ListUser)
+//	// 数据变更后使缓存失效
+//	store.Invalidate(ctx, "users")
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/auth"
+	"github.com/parkingwang/igo/pkg/requestid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderCache 响应头 标识是否命中缓存 HIT/MISS
+const HeaderCache = "X-Cache"
+
+// Entry 缓存的响应
+type Entry struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"storedAt"`
+}
+
+// Store 缓存存储
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, bool, error)
+	// Set 保存响应 tags用于批量失效
+	Set(ctx context.Context, key string, e *Entry, ttl time.Duration, tags []string) error
+	// Invalidate 使包含任一标签的缓存失效
+	Invalidate(ctx context.Context, tags ...string) error
+}
+
+// Config 缓存配置
+type Config struct {
+	// 缓存时间
+	TTL time.Duration
+	// 额外参与计算缓存key的请求头 如 Accept-Language
+	// Accept/Accept-Encoding和认证后的身份总是参与计算
+	VaryHeaders []string
+	// 标签 用于批量失效 支持使用路径参数 如 "user:{id}"
+	Tags []string
+	// 可以缓存的最大响应体 默认1MB
+	MaxBodySize int
+}
+
+// New 创建缓存中间件 需要放在rpc方法之前
+// 仅缓存GET/HEAD请求的200响应 同时处理ETag条件请求
+// 会先执行路由声明的认证和权限验证 需要认证的路由按调用方分别缓存
+func New(store Store, cfg Config) gin.HandlerFunc {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	return func(c *gin.Context) {
+		if !cacheableMethod(c.Request.Method) || noCache(c.Request) {
+			c.Next()
+			return
+		}
+		// 未通过认证的请求不能读取缓存
+		if err := web.Guard(c); err != nil {
+			web.Abort(c, err)
+			return
+		}
+		key := cacheKey(c.Request, cfg.VaryHeaders)
+		e, hit, err := store.Get(c, key)
+		if err != nil {
+			slog.WarnContext(c, "cache: get failed", slog.String("err", err.Error()))
+		}
+		markHit(c, hit)
+		if hit {
+			c.Abort()
+			serveEntry(c, e)
+			return
+		}
+
+		w := newBufferWriter(c.Writer)
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.streaming {
+			return
+		}
+		h := w.Header()
+		h.Set(HeaderCache, "MISS")
+		if w.Status() != http.StatusOK {
+			w.flush()
+			return
+		}
+		if h.Get("ETag") == "" {
+			h.Set("ETag", strongETag(w.buf.Bytes()))
+		}
+		if w.buf.Len() <= cfg.MaxBodySize && h.Get("Set-Cookie") == "" {
+			e := &Entry{
+				Status:   http.StatusOK,
+				Header:   h.Clone(),
+				Body:     append([]byte(nil), w.buf.Bytes()...),
+				StoredAt: time.Now(),
+			}
+			delete(e.Header, HeaderCache)
+			if err := store.Set(c, key, e, cfg.TTL, expandTags(c, cfg.Tags)); err != nil {
+				slog.WarnContext(c, "cache: set failed", slog.String("err", err.Error()))
+			}
+		}
+		if notModified(c.Request, h) {
+			writeNotModified(w.ResponseWriter)
+			return
+		}
+		w.flush()
+	}
+}
+
+// serveEntry 输出缓存的响应
+func serveEntry(c *gin.Context, e *Entry) {
+	h := c.Writer.Header()
+	for k, v := range e.Header {
+		h[k] = v
+	}
+	// 保留当前请求的ID
+	if id := requestid.FromContext(c); id != "" {
+		h.Set(requestid.Header, id)
+	}
+	h.Set(HeaderCache, "HIT")
+	h.Set("Age", strconv.Itoa(int(time.Since(e.StoredAt).Seconds())))
+	if notModified(c.Request, h) {
+		writeNotModified(c.Writer)
+		return
+	}
+	c.Status(e.Status)
+	c.Writer.Write(e.Body)
+}
+
+// markHit 在访问日志和span中记录是否命中缓存
+func markHit(c *gin.Context, hit bool) {
+	web.AddAccessLogAttrs(c, slog.Bool("cache.hit", hit))
+	trace.SpanFromContext(c).SetAttributes(attribute.Bool("http.cache.hit", hit))
+}
+
+// noCache 客户端要求不使用缓存
+func noCache(r *http.Request) bool {
+	cc := r.Header.Get("Cache-Control")
+	return strings.Contains(cc, "no-cache") || strings.Contains(cc, "no-store")
+}
+
+// negotiateHeaders 决定响应格式和压缩方式的请求头
+var negotiateHeaders = []string{"Accept", "Accept-Encoding"}
+
+// cacheKey 由请求方法 路径 排序后的查询参数 调用方和指定的请求头计算
+// HEAD请求的响应没有body 不能与GET共用缓存
+func cacheKey(r *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	// Encode 按key排序
+	b.WriteString(r.URL.Query().Encode())
+	if p, ok := auth.FromContext(r.Context()); ok {
+		b.WriteString("\nprincipal:")
+		b.WriteString(p.Scheme + ":" + p.Subject)
+	}
+	for _, name := range append(negotiateHeaders, vary...) {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(r.Header.Get(name))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// expandTags 替换标签中的路径参数
+func expandTags(c *gin.Context, tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	list := make([]string, 0, len(tags))
+	for _, t := range tags {
+		for _, p := range c.Params {
+			t = strings.ReplaceAll(t, "{"+p.Key+"}", p.Value)
+		}
+		list = append(list, t)
+	}
+	return list
+}
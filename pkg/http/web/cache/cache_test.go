@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/auth"
+)
+
+func TestCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	for name, store := range map[string]Store{
+		"local": Local(10),
+		"redis": NewRedis(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), ""),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var calls int
+			e := gin.New()
+			e.GET("/user/:id", New(store, Config{TTL: time.Minute, Tags: []string{"user:{id}"}}), func(c *gin.Context) {
+				calls++
+				c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+			})
+			do := func(etag string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(http.MethodGet, "/user/1?b=2&a=1", nil)
+				if etag != "" {
+					r.Header.Set("If-None-Match", etag)
+				}
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, r)
+				return w
+			}
+			first := do("")
+			if first.Header().Get(HeaderCache) != "MISS" || first.Header().Get("ETag") == "" {
+				t.Fatalf("expect miss with etag %v", first.Header())
+			}
+			second := do("")
+			if calls != 1 || second.Header().Get(HeaderCache) != "HIT" || second.Body.String() != first.Body.String() {
+				t.Fatalf("expect hit, calls %d %v", calls, second.Header())
+			}
+			if w := do(first.Header().Get("ETag")); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+				t.Fatalf("expect 304, got %d", w.Code)
+			}
+			if err := store.Invalidate(context.Background(), "user:1"); err != nil {
+				t.Fatal(err)
+			}
+			if do(""); calls != 2 {
+				t.Fatalf("expect invalidated, calls %d", calls)
+			}
+		})
+	}
+}
+
+func TestCacheMethod(t *testing.T) {
+	e := gin.New()
+	h := func(c *gin.Context) {
+		c.String(http.StatusOK, "igo")
+	}
+	mw := New(Local(10), Config{TTL: time.Minute})
+	e.GET("/ping", mw, h)
+	e.HEAD("/ping", mw, h)
+	do := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(method, "/ping", nil))
+		return w
+	}
+	// HEAD的缓存不能被GET使用
+	do(http.MethodHead)
+	w := do(http.MethodGet)
+	if w.Header().Get(HeaderCache) != "MISS" || w.Body.String() != "igo" {
+		t.Fatalf("expect get miss after head %v %q", w.Header(), w.Body)
+	}
+	// 处理函数没有设置时不输出Last-Modified
+	if lm := w.Header().Get("Last-Modified"); lm != "" {
+		t.Fatalf("unexpected Last-Modified %s", lm)
+	}
+}
+
+func TestETag(t *testing.T) {
+	e := gin.New()
+	e.Use(ETag())
+	e.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Body.String() != "hello" {
+		t.Fatalf("expect etag %v %s", w.Header(), w.Body)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expect 304, got %d", w.Code)
+	}
+}
+
+func TestCacheScope(t *testing.T) {
+	a, _ := auth.NewAPIKey(auth.APIKeyConfig{Keys: []auth.Credential{
+		{ID: "u1", Secret: "k1"},
+		{ID: "u2", Secret: "k2"},
+	}})
+	srv := web.New(web.WithAuthenticator("apikey", a))
+	// 分组的中间件在rpc方法之前执行
+	r := srv.Router().Group("", New(Local(10), Config{TTL: time.Minute}))
+	r.Get("/me", func(ctx context.Context, in *web.Empty) (string, error) {
+		p, _ := auth.FromContext(ctx)
+		return p.Subject, nil
+	}).Auth("apikey")
+	r.Get("/doc", func(ctx context.Context, in *web.Empty) (map[string]string, error) {
+		return map[string]string{"name": "igo"}, nil
+	})
+	get := func(path, header, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		srv.GinEngine().ServeHTTP(w, r)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, status int, cache, body string) {
+		t.Helper()
+		if w.Code != status || w.Header().Get(HeaderCache) != cache || !strings.Contains(w.Body.String(), body) {
+			t.Fatalf("expect %d %q %q, got %d %q %s", status, cache, body, w.Code, w.Header().Get(HeaderCache), w.Body)
+		}
+	}
+
+	expect(get("/me", "X-API-Key", "k1"), http.StatusOK, "MISS", "u1")
+	// 未认证的请求不能读取缓存
+	expect(get("/me", "", ""), http.StatusUnauthorized, "", "")
+	// 不同的调用方分别缓存
+	expect(get("/me", "X-API-Key", "k2"), http.StatusOK, "MISS", "u2")
+	expect(get("/me", "X-API-Key", "k1"), http.StatusOK, "HIT", "u1")
+
+	// 不同的响应格式分别缓存
+	expect(get("/doc", "Accept", "application/json"), http.StatusOK, "MISS", "igo")
+	expect(get("/doc", "Accept", "*/*"), http.StatusOK, "MISS", "igo")
+	w := get("/doc", "Accept", "application/json")
+	expect(w, http.StatusOK, "HIT", "igo")
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag 为GET/HEAD请求的200响应生成强ETag 并处理 If-None-Match / If-Modified-Since
+// 响应会先缓冲在内存中 调用Flush的流式响应不受影响
+func ETag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cacheableMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		w := newBufferWriter(c.Writer)
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.streaming {
+			return
+		}
+		if w.Status() == http.StatusOK {
+			h := w.Header()
+			if h.Get("ETag") == "" {
+				h.Set("ETag", strongETag(w.buf.Bytes()))
+			}
+			if notModified(c.Request, h) {
+				writeNotModified(w.ResponseWriter)
+				return
+			}
+		}
+		w.flush()
+	}
+}
+
+func cacheableMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// strongETag 基于响应体内容的强ETag
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified 根据条件请求头判断客户端的缓存是否仍然有效
+// 同时存在时 If-None-Match 优先
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, v := range strings.Split(inm, ",") {
+			v = strings.TrimSpace(v)
+			// If-None-Match 使用弱比较
+			if v == "*" || strings.TrimPrefix(v, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	lm := h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	t1, err1 := http.ParseTime(ims)
+	t2, err2 := http.ParseTime(lm)
+	return err1 == nil && err2 == nil && !t2.Truncate(time.Second).After(t1)
+}
+
+// writeNotModified 输出304 只保留与缓存相关的响应头
+func writeNotModified(w gin.ResponseWriter) {
+	h := w.Header()
+	for _, k := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+		h.Del(k)
+	}
+	w.WriteHeader(http.StatusNotModified)
+	w.WriteHeaderNow()
+}
+
+// bufferWriter 缓冲响应 以便在输出前设置响应头
+type bufferWriter struct {
+	gin.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func newBufferWriter(w gin.ResponseWriter) *bufferWriter {
+	return &bufferWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *bufferWriter) WriteHeader(code int) {
+	if code > 0 && !w.streaming {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bufferWriter) WriteHeaderNow() {
+	if w.streaming {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *bufferWriter) WriteString(s string) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.buf.WriteString(s)
+}
+
+func (w *bufferWriter) Status() int {
+	if w.streaming {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *bufferWriter) Size() int {
+	if w.streaming {
+		return w.ResponseWriter.Size()
+	}
+	return w.buf.Len()
+}
+
+func (w *bufferWriter) Written() bool {
+	return w.streaming || w.buf.Len() > 0
+}
+
+// Flush 流式响应 放弃缓冲直接输出
+func (w *bufferWriter) Flush() {
+	if !w.streaming {
+		w.flush()
+		w.streaming = true
+	}
+	w.ResponseWriter.Flush()
+}
+
+// flush 输出缓冲的响应
+func (w *bufferWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
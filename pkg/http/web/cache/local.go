@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// localStore 本地内存LRU
+type localStore struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+}
+
+type localItem struct {
+	key      string
+	entry    *Entry
+	expireAt time.Time
+	tags     []string
+}
+
+// Local 本地内存LRU 最多保存size个响应 仅在单个进程内生效
+func Local(size int) Store {
+	if size <= 0 {
+		size = 1000
+	}
+	return &localStore{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		tags:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (s *localStore) Get(_ context.Context, key string) (*Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := el.Value.(*localItem)
+	if !item.expireAt.IsZero() && time.Now().After(item.expireAt) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(el)
+	return item.entry, true, nil
+}
+
+func (s *localStore) Set(_ context.Context, key string, e *Entry, ttl time.Duration, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	item := &localItem{key: key, entry: e, tags: tags}
+	if ttl > 0 {
+		item.expireAt = time.Now().Add(ttl)
+	}
+	s.items[key] = s.ll.PushFront(item)
+	for _, t := range tags {
+		if s.tags[t] == nil {
+			s.tags[t] = make(map[string]struct{})
+		}
+		s.tags[t][key] = struct{}{}
+	}
+	for s.ll.Len() > s.size {
+		s.remove(s.ll.Back())
+	}
+	return nil
+}
+
+func (s *localStore) Invalidate(_ context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tags {
+		for key := range s.tags[t] {
+			if el, ok := s.items[key]; ok {
+				s.remove(el)
+			}
+		}
+		delete(s.tags, t)
+	}
+	return nil
+}
+
+func (s *localStore) remove(el *list.Element) {
+	item := s.ll.Remove(el).(*localItem)
+	delete(s.items, item.key)
+	for _, t := range item.tags {
+		if keys, ok := s.tags[t]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(s.tags, t)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// setWithTags 保存缓存并加入标签
+// 标签的过期时间只会延长 保证不短于其中的缓存
+var setWithTags = goredis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	local left = redis.call('PTTL', KEYS[i])
+	redis.call('SADD', KEYS[i], ARGV[3])
+	if ttl <= 0 then
+		redis.call('PERSIST', KEYS[i])
+	elseif left == -2 or (left >= 0 and left < ttl) then
+		redis.call('PEXPIRE', KEYS[i], ttl)
+	end
+end
+return 1
+`)
+
+// redisStore 多个副本共享的缓存
+// 每个标签对应一个set 记录包含此标签的缓存key
+type redisStore struct {
+	client goredis.Cmdable
+	prefix string
+}
+
+// NewRedis 使用redis保存响应 prefix默认 igo:cache:
+func NewRedis(client goredis.Cmdable, prefix string) Store {
+	if prefix == "" {
+		prefix = "igo:cache:"
+	}
+	return &redisStore{client: client, prefix: prefix}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (*Entry, bool, error) {
+	b, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false, err
+	}
+	return &e, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, e *Entry, ttl time.Duration, tags []string) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(tags)+1)
+	keys = append(keys, s.prefix+key)
+	for _, t := range tags {
+		keys = append(keys, s.tagKey(t))
+	}
+	return setWithTags.Run(ctx, s.client, keys, b, ttl.Milliseconds(), key).Err()
+}
+
+func (s *redisStore) Invalidate(ctx context.Context, tags ...string) error {
+	for _, t := range tags {
+		tkey := s.tagKey(t)
+		keys, err := s.client.SMembers(ctx, tkey).Result()
+		if err != nil {
+			return err
+		}
+		dels := make([]string, 0, len(keys)+1)
+		for _, k := range keys {
+			dels = append(dels, s.prefix+k)
+		}
+		dels = append(dels, tkey)
+		if err := s.client.Del(ctx, dels...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *redisStore) tagKey(tag string) string {
+	return s.prefix + "tag:" + tag
+}
@@ -0,0 +1,206 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+	"github.com/parkingwang/igo/pkg/http/code"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 请求体解析和响应输出的编解码
+// 请求根据Content-Type选择 响应根据Accept选择
+type Codec interface {
+	// MediaTypes 支持的媒体类型 第一个用于响应和文档
+	MediaTypes() []string
+	// Support 是否支持此类型的数据 如protobuf只支持proto.Message
+	Support(t reflect.Type) bool
+	Bind(req *http.Request, obj any) error
+	Render(obj any) render.Render
+}
+
+// 内置的编解码 默认只启用json
+var (
+	CodecJSON Codec = &codec{
+		types:  []string{binding.MIMEJSON},
+		bind:   binding.JSON,
+		render: func(obj any) render.Render { return render.JSON{Data: obj} },
+	}
+	CodecXML Codec = &codec{
+		types:  []string{binding.MIMEXML, binding.MIMEXML2},
+		bind:   binding.XML,
+		render: func(obj any) render.Render { return render.XML{Data: obj} },
+	}
+	CodecMsgPack Codec = &codec{
+		types:  []string{binding.MIMEMSGPACK, binding.MIMEMSGPACK2},
+		bind:   binding.MsgPack,
+		render: func(obj any) render.Render { return render.MsgPack{Data: obj} },
+	}
+	CodecYAML Codec = &codec{
+		types:  []string{binding.MIMEYAML, binding.MIMEYAML2},
+		bind:   binding.YAML,
+		render: func(obj any) render.Render { return render.YAML{Data: obj} },
+	}
+	CodecProtobuf Codec = &codec{
+		types: []string{binding.MIMEPROTOBUF},
+		bind:  binding.ProtoBuf,
+		support: func(t reflect.Type) bool {
+			return t != nil && t.Implements(rtypeProtoMessage)
+		},
+		render: func(obj any) render.Render { return render.ProtoBuf{Data: obj} },
+	}
+)
+
+var rtypeProtoMessage = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+var builtinCodecs = map[string]Codec{
+	"json":     CodecJSON,
+	"xml":      CodecXML,
+	"msgpack":  CodecMsgPack,
+	"yaml":     CodecYAML,
+	"protobuf": CodecProtobuf,
+}
+
+// LookupCodec 按名称获取内置的编解码 json/xml/msgpack/yaml/protobuf
+func LookupCodec(name string) (Codec, bool) {
+	c, ok := builtinCodecs[name]
+	return c, ok
+}
+
+type codec struct {
+	types   []string
+	bind    binding.Binding
+	support func(reflect.Type) bool
+	render  func(any) render.Render
+}
+
+func (c *codec) MediaTypes() []string { return c.types }
+
+func (c *codec) Support(t reflect.Type) bool {
+	if c.support == nil {
+		return true
+	}
+	return c.support(t)
+}
+
+func (c *codec) Bind(req *http.Request, obj any) error {
+	return c.bind.Bind(req, obj)
+}
+
+func (c *codec) Render(obj any) render.Render {
+	return c.render(obj)
+}
+
+// codecSet 已注册的编解码 按注册顺序协商 第一个为默认
+type codecSet struct {
+	list   []Codec
+	byType map[string]Codec
+}
+
+func newCodecSet(cs ...Codec) *codecSet {
+	s := &codecSet{byType: make(map[string]Codec)}
+	s.add(cs...)
+	return s
+}
+
+// add 添加编解码 相同媒体类型的会被替换
+func (s *codecSet) add(cs ...Codec) {
+	for _, c := range cs {
+		replaced := false
+		for i, old := range s.list {
+			if old.MediaTypes()[0] == c.MediaTypes()[0] {
+				s.list[i] = c
+				replaced = true
+			}
+		}
+		if !replaced {
+			s.list = append(s.list, c)
+		}
+		for _, t := range c.MediaTypes() {
+			s.byType[t] = c
+		}
+	}
+}
+
+// forRequest 根据Content-Type返回编解码
+func (s *codecSet) forRequest(contentType string) (Codec, bool) {
+	c, ok := s.byType[contentType]
+	return c, ok
+}
+
+// forType 返回支持此类型数据的编解码
+func (s *codecSet) forType(t reflect.Type) []Codec {
+	list := make([]Codec, 0, len(s.list))
+	for _, c := range s.list {
+		if c.Support(t) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// negotiate 根据Accept选择编解码 未携带Accept时使用第一个
+// 无法满足Accept时返回第一个和false
+func (s *codecSet) negotiate(c *gin.Context, obj any) (Codec, bool) {
+	list := s.forType(reflect.TypeOf(obj))
+	if len(list) == 0 {
+		return CodecJSON, true
+	}
+	offered := s.offered(list)
+	if accepted := c.NegotiateFormat(offered...); accepted != "" {
+		if v, ok := s.byType[accepted]; ok {
+			return v, true
+		}
+	}
+	// 带后缀的媒体类型 如 application/vnd.igo.v2+json 按json处理
+	for _, accepted := range c.Accepted {
+		_, suffix, ok := strings.Cut(accepted, "+")
+		if !ok {
+			continue
+		}
+		if v, ok := s.byType["application/"+suffix]; ok && slices.Contains(list, v) {
+			return v, true
+		}
+	}
+	return list[0], false
+}
+
+func (s *codecSet) offered(list []Codec) []string {
+	offered := make([]string, 0, len(list))
+	for _, v := range list {
+		offered = append(offered, v.MediaTypes()...)
+	}
+	return offered
+}
+
+// codecBinding 适配gin的binding.Binding
+type codecBinding struct {
+	Codec
+}
+
+func (b codecBinding) Name() string {
+	return b.MediaTypes()[0]
+}
+
+// Negotiate 根据请求的Accept头选择合适的格式输出
+// 自定义Renderer时使用此方法替代ctx.JSON
+// 成功的响应无法满足Accept时返回406 错误响应使用默认格式输出
+func Negotiate(ctx *gin.Context, status int, obj any) {
+	opt, ok := optionFrom(ctx)
+	if !ok {
+		ctx.JSON(status, obj)
+		return
+	}
+	c, ok := opt.codecs.negotiate(ctx, obj)
+	if !ok && status < http.StatusBadRequest {
+		offered := opt.codecs.offered(opt.codecs.forType(reflect.TypeOf(obj)))
+		warpRender(opt, ctx, nil, code.NewCodeError(http.StatusNotAcceptable, "not acceptable, supported: %s", strings.Join(offered, ", ")))
+		return
+	}
+	ctx.Render(status, c.Render(obj))
+}
@@ -0,0 +1,124 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func codecRoutes(r web.Router) {
+	r.Post("/user", func(ctx context.Context, in *testUser) (*testUser, error) {
+		if in.ID == 0 {
+			return nil, code.NewNotfoundError("user not found")
+		}
+		return in, nil
+	})
+	r.Post("/echo", func(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String("echo " + in.Value), nil
+	})
+}
+
+// postUser 以json提交用户 accept为空时不设置Accept
+func postUser(h http.Handler, u testUser, accept string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(u)
+	header := []string{"Content-Type", "application/json"}
+	if accept != "" {
+		header = append(header, "Accept", accept)
+	}
+	return do(h, http.MethodPost, "/user", bytes.NewReader(body), header...)
+}
+
+func TestCodecNegotiate(t *testing.T) {
+	h := newTestServer(t, codecRoutes, web.WithCodec(web.CodecXML, web.CodecYAML, web.CodecMsgPack))
+
+	cases := map[string]string{
+		"":                             "application/json",
+		"*/*":                          "application/json",
+		"application/xml":              "application/xml",
+		"text/html, application/yaml":  "application/yaml",
+		"application/x-msgpack":        "application/msgpack",
+		"application/vnd.igo.v2+json":  "application/json",
+		"application/vnd.igo.v2+xml":   "application/xml",
+		"application/json; version=v2": "application/json",
+	}
+	for accept, want := range cases {
+		w := postUser(h, testUser{ID: 1, Name: "igo"}, accept)
+		if w.Code != http.StatusOK {
+			t.Fatalf("accept %q: %d %s", accept, w.Code, w.Body)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, want) {
+			t.Errorf("accept %q: got %s, want %s", accept, ct, want)
+		}
+	}
+	if w := postUser(h, testUser{ID: 1, Name: "igo"}, "application/xml"); !strings.Contains(w.Body.String(), "<user><id>1</id><name>igo</name></user>") {
+		t.Fatalf("xml response %s", w.Body)
+	}
+
+	// 请求体按Content-Type解析
+	var out testUser
+	w := do(h, http.MethodPost, "/user", strings.NewReader("<user><id>2</id><name>xml</name></user>"), "Content-Type", "application/xml")
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.ID != 2 || out.Name != "xml" {
+		t.Fatalf("xml request %d %s", w.Code, w.Body)
+	}
+	w = do(h, http.MethodPost, "/user", strings.NewReader("id: 3\nname: yaml\n"), "Content-Type", "application/yaml")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"yaml"`) {
+		t.Fatalf("yaml request %d %s", w.Code, w.Body)
+	}
+}
+
+func TestCodecNotAcceptable(t *testing.T) {
+	h := newTestServer(t, codecRoutes, web.WithCodec(web.CodecXML, web.CodecProtobuf))
+
+	w := postUser(h, testUser{ID: 1}, "text/html")
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expect 406, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("406 should use the default format: %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), "application/xml") {
+		t.Fatalf("unexpected %s", w.Body)
+	}
+	// protobuf只支持proto.Message
+	if w := postUser(h, testUser{ID: 1}, "application/x-protobuf"); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expect 406, got %d", w.Code)
+	}
+	// 错误响应不返回406
+	if w := postUser(h, testUser{}, "text/html"); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "user not found") {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+}
+
+func TestCodecProtobuf(t *testing.T) {
+	h := newTestServer(t, codecRoutes, web.WithCodec(web.CodecProtobuf))
+	body, err := proto.Marshal(wrapperspb.String("igo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := do(h, http.MethodPost, "/echo", bytes.NewReader(body), "Content-Type", "application/x-protobuf", "Accept", "application/x-protobuf")
+	var out wrapperspb.StringValue
+	if err := proto.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Value != "echo igo" {
+		t.Fatalf("protobuf response %d %v %v", w.Code, out.Value, err)
+	}
+	// 未指定时使用第一个注册的json
+	w = do(h, http.MethodPost, "/echo", bytes.NewReader(body), "Content-Type", "application/x-protobuf")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"value":"echo igo"`) {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+
+	if _, ok := web.LookupCodec("msgpack"); !ok {
+		t.Fatal("builtin msgpack not found")
+	}
+	if _, ok := web.LookupCodec("gob"); ok {
+		t.Fatal("unknown codec should not be found")
+	}
+}
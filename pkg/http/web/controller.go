@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 控制器可选实现的方法
+type (
+	// controllerPrefix 分组的路径
+	controllerPrefix interface {
+		Prefix() string
+	}
+	// controllerMiddleware 分组的中间件
+	controllerMiddleware interface {
+		Middleware() []gin.HandlerFunc
+	}
+	// controllerRoutes 自行注册路由
+	controllerRoutes interface {
+		Routes(r Router)
+	}
+)
+
+// 按方法名注册时的约定 名称完全一致
+var controllerActions = map[string][2]string{
+	"List":   {http.MethodGet, "/"},
+	"Get":    {http.MethodGet, "/:id"},
+	"Create": {http.MethodPost, "/"},
+	"Update": {http.MethodPut, "/:id"},
+	"Patch":  {http.MethodPatch, "/:id"},
+	"Delete": {http.MethodDelete, "/:id"},
+}
+
+// 按方法名注册时的约定 前缀为请求方法 其余部分为路径 如 PostLogin -> POST /login
+var controllerMethodPrefixes = []string{"Get", "Post", "Put", "Patch", "Delete"}
+
+// registerController 创建分组并注册控制器的路由
+func (s *route) registerController(c any) GroupCommenter {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	base := t
+	if base.Kind() == reflect.Pointer {
+		base = base.Elem()
+	}
+	prefix := "/" + kebabCase(strings.TrimSuffix(base.Name(), "Controller"))
+	if p, ok := c.(controllerPrefix); ok {
+		prefix = p.Prefix()
+	}
+	var middleware []gin.HandlerFunc
+	if m, ok := c.(controllerMiddleware); ok {
+		middleware = m.Middleware()
+	}
+	g := s.Group(prefix, middleware...).(*route)
+	// 分组的说明使用控制器类型的注释
+	if summary, _ := lookupDocComment(base.PkgPath() + "." + base.Name()); summary != "" {
+		g.Comment(summary)
+	}
+
+	if r, ok := c.(controllerRoutes); ok {
+		r.Routes(g)
+		return g
+	}
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		method, path, ok := controllerRoute(m.Name)
+		if !ok {
+			continue
+		}
+		if _, ok := checkHandleValid(v.Method(i).Type()); !ok {
+			continue
+		}
+		cm := g.Handle(method, path, v.Method(i).Interface())
+		// 通过反射获取的方法值没有名称 使用方法表达式的名称
+		if r, ok := cm.(*route); ok && r.info != nil {
+			r.info.pcName = funcName(m.Func.Interface())
+			r.info.comment, r.info.description = lookupDocComment(r.info.pcName)
+		}
+	}
+	return g
+}
+
+// controllerRoute 根据方法名返回请求方法和路径
+func controllerRoute(name string) (string, string, bool) {
+	if v, ok := controllerActions[name]; ok {
+		return v[0], v[1], true
+	}
+	for _, p := range controllerMethodPrefixes {
+		if rest, ok := strings.CutPrefix(name, p); ok && rest != "" && unicode.IsUpper(rune(rest[0])) {
+			return strings.ToUpper(p), "/" + kebabCase(rest), true
+		}
+	}
+	return "", "", false
+}
+
+// kebabCase OrderItem -> order-item HTTPServer -> http-server
+func kebabCase(s string) string {
+	rs := []rune(s)
+	var b strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]))) {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
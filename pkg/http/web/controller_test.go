@@ -0,0 +1,75 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+	"github.com/parkingwang/igo/pkg/http/web/webtest"
+)
+
+const pkgPath = "github.com/parkingwang/igo/pkg/http/web_test"
+
+type userController struct{}
+
+func (uc *userController) Get(ctx context.Context, in *testUserRequest) (*testUser, error) {
+	return getUser(ctx, in)
+}
+
+func (uc *userController) PostResetPassword(ctx context.Context, in *testUserRequest) (*web.Empty, error) {
+	return &web.Empty{}, nil
+}
+
+// helper 签名不符合 不注册
+func (uc *userController) GetHelper() string {
+	return ""
+}
+
+type orderController struct{}
+
+func (oc *orderController) Prefix() string {
+	return "/orders"
+}
+
+func (oc *orderController) Routes(r web.Router) {
+	r.Get("/:id", oc.Get)
+}
+
+func (oc *orderController) Get(ctx context.Context, in *testUserRequest) (*testUser, error) {
+	return getUser(ctx, in)
+}
+
+func TestController(t *testing.T) {
+	web.RegisterDocComments(map[string]string{
+		pkgPath + ".userController":        "用户管理",
+		pkgPath + ".(*userController).Get": "获取用户\n按id查询",
+	})
+	s := webtest.New(t, func(r web.Router) {
+		r.Controller(&userController{})
+		r.Controller(&orderController{})
+	})
+	s.Get("/user/1").ExpectStatus(http.StatusOK).ExpectBodyContains(`"name":"igo"`)
+	s.Post("/user/reset-password").ExpectStatus(http.StatusOK)
+	s.Get("/orders/2").ExpectStatus(http.StatusOK).ExpectBodyContains(`"id":2`)
+
+	spec, err := s.Server().OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := spec.Paths["/user/helper"]; ok {
+		t.Fatal("invalid handler registered")
+	}
+	op := spec.Paths["/user/{id}"]["get"].(oas.Request)
+	if op.Summary != "获取用户" || op.Description != "按id查询" {
+		t.Fatalf("unexpected comment %+v", op.RequestComment)
+	}
+	found := false
+	for _, tag := range spec.Tags {
+		found = found || tag.Description == "用户管理"
+	}
+	if !found {
+		t.Fatalf("controller comment not in tags %+v", spec.Tags)
+	}
+}
@@ -0,0 +1,35 @@
+package web
+
+import (
+	"strings"
+	"sync"
+)
+
+var (
+	docCommentsMu sync.RWMutex
+	docComments   = make(map[string]string)
+)
+
+// RegisterDocComments 注册函数和类型的注释 由 cmd/igodoc 生成的代码调用
+// key为运行时的名称 如 main.GetUser main.(*UserController).Get
+// 注释的第一行作为路由的说明 其余作为描述
+func RegisterDocComments(m map[string]string) {
+	docCommentsMu.Lock()
+	defer docCommentsMu.Unlock()
+	for k, v := range m {
+		docComments[k] = v
+	}
+}
+
+// lookupDocComment 返回注释的第一行和其余部分
+func lookupDocComment(name string) (string, string) {
+	docCommentsMu.RLock()
+	defer docCommentsMu.RUnlock()
+	text, ok := docComments[name]
+	// 值接收者的方法通过指针获取时名称为 pkg.(*T).M
+	if i := strings.Index(name, "(*"); !ok && i >= 0 {
+		text = docComments[name[:i]+strings.Replace(name[i+2:], ")", "", 1)]
+	}
+	summary, desc, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(summary), strings.TrimSpace(desc)
+}
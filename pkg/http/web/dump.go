@@ -0,0 +1,387 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DumpOption 请求/响应体输出到日志
+type DumpOption struct {
+	// 输出请求体
+	Request bool
+	// 输出响应体 流式响应不输出
+	Response bool
+	// 每个body最多输出的字节数 默认4096
+	MaxBodySize int
+	// 采样比例 (0,1] 默认1 全部输出
+	SampleRate float64
+	// 只在错误响应(状态码>=400)时输出
+	OnlyError bool
+	// 需要脱敏的字段 支持通配符 不区分大小写 为空使用 DefaultDumpMaskKeys
+	// 请求和响应结构中 `mask:"true"` 的字段同样会被脱敏
+	MaskKeys []string
+}
+
+// DefaultDumpMaskKeys 默认需要脱敏的字段
+var DefaultDumpMaskKeys = []string{
+	"*password*",
+	"*token*",
+	"*secret*",
+	"*accesskey*",
+	"authorization",
+}
+
+// WithDump 所有rpc路由的请求/响应输出设置 路由可以通过Dump覆盖
+func WithDump(d DumpOption) Option {
+	return func(o *option) {
+		o.dump = d
+	}
+}
+
+func (d DumpOption) enabled() bool {
+	return d.Request || d.Response
+}
+
+func (d DumpOption) sampled() bool {
+	return d.SampleRate <= 0 || d.SampleRate >= 1 || rand.Float64() < d.SampleRate
+}
+
+// dumperCache 路由的Dump在注册handler之后设置 第一次请求时创建dumper
+type dumperCache struct {
+	once  sync.Once
+	types []reflect.Type
+	d     *dumper
+}
+
+func newDumperCache(types ...reflect.Type) *dumperCache {
+	return &dumperCache{types: types}
+}
+
+// get 未启用时返回nil
+func (c *dumperCache) get(opt *option, info *routeInfo) *dumper {
+	c.once.Do(func() {
+		d := opt.dump
+		if info != nil {
+			if v := info.dumpValue(); v != nil {
+				d = *v
+			}
+		}
+		if d.enabled() {
+			c.d = newDumper(d, c.types...)
+		}
+	})
+	return c.d
+}
+
+// dumper 一个路由的输出设置
+type dumper struct {
+	opt  DumpOption
+	mask func(key string) bool
+}
+
+// newDumper 收集请求和响应结构中需要脱敏的字段
+func newDumper(d DumpOption, types ...reflect.Type) *dumper {
+	if d.MaxBodySize <= 0 {
+		d.MaxBodySize = 4096
+	}
+	patterns := d.MaskKeys
+	if len(patterns) == 0 {
+		patterns = DefaultDumpMaskKeys
+	}
+	lower := make([]string, 0, len(patterns))
+	for _, v := range patterns {
+		lower = append(lower, strings.ToLower(v))
+	}
+	fields := make(map[string]bool)
+	for _, t := range types {
+		findMaskFields(t, fields, make(map[reflect.Type]bool))
+	}
+	return &dumper{
+		opt: d,
+		mask: func(key string) bool {
+			key = strings.ToLower(key)
+			if fields[key] {
+				return true
+			}
+			for _, p := range lower {
+				if ok, _ := path.Match(p, key); ok {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// findMaskFields 查找 `mask:"true"` 的字段 使用json/form的名称
+func findMaskFields(t reflect.Type, m map[string]bool, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("mask") == "true" {
+			m[strings.ToLower(field.Name)] = true
+			for _, tag := range []string{"json", "form", "query", "header"} {
+				if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+					m[strings.ToLower(name)] = true
+				}
+			}
+		}
+		findMaskFields(field.Type, m, seen)
+	}
+}
+
+// begin 开始记录 返回请求结束时调用的函数
+func (d *dumper) begin(c *gin.Context) func() {
+	if !d.opt.sampled() {
+		return func() {}
+	}
+	var (
+		reqBody *limitBuffer
+		resBody *limitBuffer
+		w       *dumpWriter
+	)
+	if d.opt.Request && c.Request.Body != nil && c.Request.Body != http.NoBody {
+		reqBody = &limitBuffer{max: d.opt.MaxBodySize}
+		c.Request.Body = &teeReadCloser{c.Request.Body, reqBody}
+	}
+	if d.opt.Response {
+		resBody = &limitBuffer{max: d.opt.MaxBodySize}
+		w = &dumpWriter{ResponseWriter: c.Writer, buf: resBody}
+		c.Writer = w
+	}
+	return func() {
+		if w != nil {
+			c.Writer = w.ResponseWriter
+		}
+		status := c.Writer.Status()
+		if d.opt.OnlyError && status < http.StatusBadRequest {
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.FullPath()),
+			slog.Int("status", status),
+		}
+		if reqBody != nil {
+			attrs = append(attrs, slog.String("request", d.format(c.Request.Header.Get("Content-Type"), reqBody)))
+		}
+		if resBody != nil && !w.stream {
+			attrs = append(attrs, slog.String("response", d.format(c.Writer.Header().Get("Content-Type"), resBody)))
+		}
+		slog.LogAttrs(c, slog.LevelInfo, "gin.dump", attrs...)
+	}
+}
+
+// format 脱敏 无法脱敏的内容只输出类型和大小
+func (d *dumper) format(contentType string, b *limitBuffer) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var s string
+	switch {
+	case b.total == 0:
+		return ""
+	case mediaType == "application/x-www-form-urlencoded":
+		s = d.maskForm(b.String())
+	case isJSONMedia(mediaType) || (mediaType == "" && looksJSON(b.Bytes())):
+		s = d.maskJSON(b.String())
+	default:
+		if mediaType == "" {
+			mediaType = "unknown"
+		}
+		return "[" + mediaType + " " + formatSize(b.total) + "]"
+	}
+	if b.total > len(b.Bytes()) {
+		s += "...[truncated " + formatSize(b.total) + "]"
+	}
+	return s
+}
+
+// isJSONMedia 只输出可以脱敏的json 其他文本格式(xml/yaml等)中的字段无法可靠识别
+func isJSONMedia(t string) bool {
+	return t == "application/json" || strings.HasSuffix(t, "+json")
+}
+
+// looksJSON 没有Content-Type时按内容判断
+func looksJSON(b []byte) bool {
+	b = bytes.TrimLeft(b, " \t\r\n")
+	return len(b) > 0 && (b[0] == '{' || b[0] == '[')
+}
+
+// maskJSON 不完整的json也需要脱敏 所以不解析json
+// 匹配的字段无论值是字符串 数字还是对象/数组 都整体输出为"***"
+func (d *dumper) maskJSON(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '"' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := skipJSONString(s, i)
+		b.WriteString(s[i:end])
+		key := s[i:end]
+		i = end
+		// 字符串之后是冒号时为key
+		j := i
+		for j < len(s) && isJSONSpace(s[j]) {
+			j++
+		}
+		if j >= len(s) || s[j] != ':' {
+			continue
+		}
+		j++
+		for j < len(s) && isJSONSpace(s[j]) {
+			j++
+		}
+		if !d.mask(strings.Trim(key, `"`)) {
+			b.WriteString(s[i:j])
+			i = j
+			continue
+		}
+		b.WriteString(s[i:j])
+		b.WriteString(`"***"`)
+		i = skipJSONValue(s, j)
+	}
+	return b.String()
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// skipJSONString 返回字符串结束后的位置 截断时返回len(s)
+func skipJSONString(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// skipJSONValue 返回值结束后的位置 对象和数组跳过到匹配的括号
+func skipJSONValue(s string, i int) int {
+	if i >= len(s) {
+		return i
+	}
+	switch s[i] {
+	case '"':
+		return skipJSONString(s, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(s); j++ {
+			switch s[j] {
+			case '"':
+				j = skipJSONString(s, j) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(s)
+	}
+	j := i
+	for j < len(s) && !isJSONSpace(s[j]) && !strings.ContainsRune(",}]", rune(s[j])) {
+		j++
+	}
+	return j
+}
+
+func (d *dumper) maskForm(s string) string {
+	parts := strings.Split(s, "&")
+	for i, p := range parts {
+		k, _, ok := strings.Cut(p, "=")
+		if !ok {
+			continue
+		}
+		if key, err := url.QueryUnescape(k); err == nil && d.mask(key) {
+			parts[i] = k + "=***"
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func formatSize(n int) string {
+	return strconv.Itoa(n) + " bytes"
+}
+
+// limitBuffer 只保存前max字节 记录总大小
+type limitBuffer struct {
+	bytes.Buffer
+	max   int
+	total int
+}
+
+func (b *limitBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if remain := b.max - b.Len(); remain > 0 {
+		if len(p) > remain {
+			b.Buffer.Write(p[:remain])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		t.w.Write(p[:n])
+	}
+	return n, err
+}
+
+// dumpWriter 记录响应体 不影响输出
+type dumpWriter struct {
+	gin.ResponseWriter
+	buf *limitBuffer
+	// 调用过Flush的视为流式响应
+	stream bool
+}
+
+func (w *dumpWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.buf.Write(b[:n])
+	return n, err
+}
+
+func (w *dumpWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.buf.Write([]byte(s[:n]))
+	return n, err
+}
+
+func (w *dumpWriter) Flush() {
+	w.stream = true
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaskJSON(t *testing.T) {
+	d := newDumper(DumpOption{Request: true})
+	cases := map[string]string{
+		`{"name":"igo","password":"123"}`:              `{"name":"igo","password":"***"}`,
+		`{"accessKey": 123, "n": 1}`:                   `{"accessKey": "***", "n": 1}`,
+		`{"secret":{"a":"b","c":[1,2]},"n":1}`:         `{"secret":"***","n":1}`,
+		`{"tokens":["t1","t2"],"n":1}`:                 `{"tokens":"***","n":1}`,
+		`{"user":{"password":"x\"y","tags":["a,b"]}}`:  `{"user":{"password":"***","tags":["a,b"]}}`,
+		`[{"token":null},{"name":"password"}]`:         `[{"token":"***"},{"name":"password"}]`,
+		`{"name":"igo","password":"12`:                 `{"name":"igo","password":"***"`,
+		`{"secret":{"a":"b`:                            `{"secret":"***"`,
+		`{"Authorization" : "Bearer x" , "n":"token"}`: `{"Authorization" : "***" , "n":"token"}`,
+	}
+	for in, want := range cases {
+		if got := d.maskJSON(in); got != want {
+			t.Errorf("%s:\n got %s\nwant %s", in, got, want)
+		}
+	}
+}
+
+func TestDumpFormat(t *testing.T) {
+	d := newDumper(DumpOption{Request: true, MaxBodySize: 16})
+	format := func(contentType, body string) string {
+		b := &limitBuffer{max: d.opt.MaxBodySize}
+		b.Write([]byte(body))
+		return d.format(contentType, b)
+	}
+	if s := format("application/x-www-form-urlencoded", "a=1&password=2"); s != "a=1&password=***" {
+		t.Errorf("form %s", s)
+	}
+	// 无法脱敏的格式不输出内容
+	for _, ct := range []string{"application/xml", "application/x-yaml", "text/plain", "image/png"} {
+		if s := format(ct, "<password>x</password>"); strings.Contains(s, "password") {
+			t.Errorf("%s should not be dumped: %s", ct, s)
+		}
+	}
+	if s := format("", "password: x"); strings.Contains(s, "password") {
+		t.Errorf("unknown type should not be dumped: %s", s)
+	}
+	if s := format("", `{"password":"x"}`); s != `{"password":"***"}` {
+		t.Errorf("json without content type %s", s)
+	}
+	// 截断
+	if s := format("application/json", `{"name":"igo","password":"123"}`); s != `{"name":"igo","p...[truncated 31 bytes]` {
+		t.Errorf("truncated %s", s)
+	}
+}
+
+func TestDumpSampling(t *testing.T) {
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(old)
+
+	srv := New(WithPProf(false))
+	echo := func(ctx context.Context, in *struct {
+		Password string `json:"password"`
+	}) (string, error) {
+		return "ok", nil
+	}
+	srv.Router().Post("/all", echo).Dump(DumpOption{Request: true, Response: true})
+	srv.Router().Post("/none", echo).Dump(DumpOption{Request: true, SampleRate: 1e-9})
+	srv.Router().Post("/error", echo).Dump(DumpOption{Request: true, OnlyError: true})
+	h := srv.GinEngine()
+	dumps := func(path string) int {
+		buf.Reset()
+		for i := 0; i < 10; i++ {
+			r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"password":"123456"}`))
+			r.Header.Set("Content-Type", "application/json")
+			h.ServeHTTP(httptest.NewRecorder(), r)
+		}
+		if strings.Contains(buf.String(), "123456") {
+			t.Errorf("%s: password not masked: %s", path, buf.String())
+		}
+		return strings.Count(buf.String(), "gin.dump")
+	}
+	if n := dumps("/all"); n != 10 {
+		t.Errorf("expect all dumped, got %d", n)
+	}
+	if n := dumps("/none"); n != 0 {
+		t.Errorf("expect none sampled, got %d", n)
+	}
+	if n := dumps("/error"); n != 0 {
+		t.Errorf("expect only error dumped, got %d", n)
+	}
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+	"github.com/parkingwang/igo/pkg/requestid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Envelope 统一的响应结构
+// 成功 {"code":0,"msg":"ok","data":...}
+// 失败 {"code":404,"msg":"user not found","traceid":"...","requestid":"..."}
+type Envelope struct {
+	// 字段名 默认 code/msg/data
+	CodeKey string
+	MsgKey  string
+	DataKey string
+	// 为true时HTTP状态码固定为200 错误只体现在code字段
+	// 否则HTTP状态码和code保持一致
+	AlwaysOK bool
+}
+
+var builtinEnvelopes = map[string]Envelope{
+	"standard":  {},
+	"always200": {AlwaysOK: true},
+}
+
+// LookupEnvelope 按名称获取内置的响应结构
+// standard: HTTP状态码与错误码一致
+// always200: HTTP状态码固定200
+func LookupEnvelope(name string) (Envelope, bool) {
+	e, ok := builtinEnvelopes[name]
+	return e, ok
+}
+
+func (e Envelope) keys() (string, string, string) {
+	c, m, d := e.CodeKey, e.MsgKey, e.DataKey
+	if c == "" {
+		c = "code"
+	}
+	if m == "" {
+		m = "msg"
+	}
+	if d == "" {
+		d = "data"
+	}
+	return c, m, d
+}
+
+// Render 实现Renderer
+func (e Envelope) Render(ctx *gin.Context, data any, err error) {
+	// 使用gin.Context已经自行输出了
+	if ctx.Writer.Written() {
+		return
+	}
+	codeKey, msgKey, dataKey := e.keys()
+	if err != nil {
+		var ce *code.CodeError
+		if !errors.As(err, &ce) {
+			ce = &code.CodeError{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			}
+		}
+		span := trace.SpanFromContext(ctx)
+		span.SetStatus(codes.Error, err.Error())
+		status := ce.Code
+		if e.AlwaysOK {
+			status = http.StatusOK
+		}
+		Negotiate(ctx, status, gin.H{
+			codeKey:     ce.Code,
+			msgKey:      ce.Message,
+			"traceid":   span.SpanContext().TraceID().String(),
+			"requestid": requestid.FromContext(ctx),
+		})
+		return
+	}
+	Negotiate(ctx, http.StatusOK, gin.H{
+		codeKey: 0,
+		msgKey:  "ok",
+		dataKey: data,
+	})
+}
+
+// Schema 文档中的响应结构 与Render保持一致
+func (e Envelope) Schema(data oas.Schema) oas.Schema {
+	codeKey, msgKey, dataKey := e.keys()
+	return oas.Schema{
+		Type:     "object",
+		Required: []string{codeKey, msgKey},
+		Properties: map[string]oas.Schema{
+			codeKey: {Type: "integer", Description: "错误码 0表示成功"},
+			msgKey:  {Type: "string", Description: "错误信息"},
+			dataKey: data,
+		},
+	}
+}
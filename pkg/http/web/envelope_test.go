@@ -0,0 +1,102 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+)
+
+func envelopeRoutes(r web.Router) {
+	r.Get("/user/:id", getUser)
+}
+
+func TestEnvelope(t *testing.T) {
+	h := newTestServer(t, envelopeRoutes, web.WithEnvelope(web.Envelope{}))
+
+	var ok struct {
+		Code int      `json:"code"`
+		Msg  string   `json:"msg"`
+		Data testUser `json:"data"`
+	}
+	w := do(h, http.MethodGet, "/user/1", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &ok); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+	if ok.Code != 0 || ok.Msg != "ok" || ok.Data.ID != 1 || ok.Data.Name != "igo" {
+		t.Fatalf("unexpected %+v", ok)
+	}
+
+	var fail struct {
+		Code      int    `json:"code"`
+		Msg       string `json:"msg"`
+		TraceID   string `json:"traceid"`
+		RequestID string `json:"requestid"`
+	}
+	// HTTP状态码与错误码一致
+	w = do(h, http.MethodGet, "/user/404", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &fail); err != nil || w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+	if fail.Code != http.StatusNotFound || fail.Msg != "user not found" || fail.TraceID == "" || fail.RequestID == "" {
+		t.Fatalf("unexpected %+v", fail)
+	}
+	if w := do(h, http.MethodGet, "/user/500", nil); w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"code":500`) {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+	// 绑定失败同样使用统一结构
+	if w := do(h, http.MethodGet, "/user/abc", nil); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"code":400`) {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+}
+
+func TestEnvelopeAlwaysOK(t *testing.T) {
+	e, ok := web.LookupEnvelope("always200")
+	if !ok || !e.AlwaysOK {
+		t.Fatalf("builtin always200 %+v %v", e, ok)
+	}
+	if _, ok := web.LookupEnvelope("unknown"); ok {
+		t.Fatal("unknown envelope should not be found")
+	}
+	h := newTestServer(t, envelopeRoutes, web.WithEnvelope(e))
+	if w := do(h, http.MethodGet, "/user/404", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"code":404`) {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodGet, "/user/1", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"code":0`) {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+}
+
+func TestEnvelopeKeys(t *testing.T) {
+	e := web.Envelope{CodeKey: "errcode", MsgKey: "errmsg", DataKey: "result"}
+	h := newTestServer(t, envelopeRoutes, web.WithEnvelope(e))
+
+	var out map[string]any
+	w := do(h, http.MethodGet, "/user/1", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+	if out["errcode"] != float64(0) || out["errmsg"] != "ok" || out["result"] == nil {
+		t.Fatalf("unexpected %v", out)
+	}
+	if w := do(h, http.MethodGet, "/user/404", nil); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), `"errmsg":"user not found"`) {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+
+	// 文档中的响应结构同步包装
+	schema := e.Schema(oas.Schema{Type: "object"})
+	for _, k := range []string{"errcode", "errmsg", "result"} {
+		if _, ok := schema.Properties[k]; !ok {
+			t.Errorf("schema missing %s: %v", k, schema.Properties)
+		}
+	}
+	if schema.Properties["result"].Type != "object" {
+		t.Errorf("data schema not wrapped %v", schema.Properties["result"])
+	}
+	if len(schema.Required) != 2 || schema.Required[0] != "errcode" || schema.Required[1] != "errmsg" {
+		t.Errorf("unexpected required %v", schema.Required)
+	}
+}
@@ -0,0 +1,254 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/code"
+)
+
+// File 上传的文件 需要使用form tag并以multipart/form-data方式提交
+// 通过 file tag 限制文件大小和类型 多个类型使用|分隔 支持通配符
+//
+//	Avatar web.File   `form:"avatar" binding:"required" file:"maxsize=2MB,mime=image/*"`
+//	Docs   []web.File `form:"docs" file:"maxsize=10MB,mime=application/pdf|text/plain"`
+type File = *multipart.FileHeader
+
+var (
+	rtypeFile         = reflect.TypeOf((File)(nil))
+	rtypeFileResponse = reflect.TypeOf(&FileResponse{})
+)
+
+// FileResponse 文件下载 作为rpc方法的返回值使用
+// 支持Range断点续传和If-Modified-Since
+type FileResponse struct {
+	// 下载时的文件名
+	Name string
+	// 为空时根据文件名后缀判断
+	ContentType string
+	ModTime     time.Time
+	// 实现了io.Closer时 输出完成后自动关闭
+	Content io.ReadSeeker
+	// 浏览器内直接打开而不是下载
+	Inline bool
+}
+
+// NewFileResponse 读取本地文件作为下载内容
+func NewFileResponse(path string) (*FileResponse, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileResponse{
+		Name:    filepath.Base(path),
+		ModTime: stat.ModTime(),
+		Content: f,
+	}, nil
+}
+
+func serveFile(c *gin.Context, f *FileResponse) {
+	if closer, ok := f.Content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	// 大文件下载不受WriteTimeout限制
+	clearWriteDeadline(c.Writer)
+	disposition := "attachment"
+	if f.Inline {
+		disposition = "inline"
+	}
+	if f.Name != "" {
+		// 自动处理非ascii文件名 filename*=utf-8''...
+		disposition = mime.FormatMediaType(disposition, map[string]string{"filename": f.Name})
+	}
+	c.Header("Content-Disposition", disposition)
+	if f.ContentType != "" {
+		c.Header("Content-Type", f.ContentType)
+	}
+	http.ServeContent(c.Writer, c.Request, f.Name, f.ModTime, f.Content)
+}
+
+// fileRule 上传文件的限制
+type fileRule struct {
+	index   []int
+	name    string
+	maxSize int64
+	mimes   []string
+}
+
+// parseFileRules 找出请求参数中带有file tag的字段
+func parseFileRules(t reflect.Type, parent []int, rules []fileRule) []fileRule {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return rules
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+		if field.Anonymous {
+			rules = parseFileRules(field.Type, index, rules)
+			continue
+		}
+		if field.Type != rtypeFile && !(field.Type.Kind() == reflect.Slice && field.Type.Elem() == rtypeFile) {
+			continue
+		}
+		tag, ok := field.Tag.Lookup("file")
+		if !ok {
+			continue
+		}
+		rule := fileRule{index: index, name: field.Tag.Get("form")}
+		for _, part := range strings.Split(tag, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch k {
+			case "maxsize":
+				n, err := parseByteSize(v)
+				if err != nil {
+					panic(fmt.Sprintf("field %s file tag: %s", field.Name, err))
+				}
+				rule.maxSize = n
+			case "mime":
+				rule.mimes = strings.Split(v, "|")
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// checkFiles 验证上传文件的大小和类型 超出大小时返回413 类型不允许时返回415
+// 类型通过文件内容判断 不信任客户端提交的Content-Type
+func checkFiles(v reflect.Value, rules []fileRule) error {
+	v = reflect.Indirect(v)
+	for _, rule := range rules {
+		fv, err := v.FieldByIndexErr(rule.index)
+		if err != nil {
+			// 嵌入的指针为空
+			continue
+		}
+		var files []File
+		if fv.Kind() == reflect.Slice {
+			files = fv.Interface().([]File)
+		} else if !fv.IsNil() {
+			files = []File{fv.Interface().(File)}
+		}
+		for _, f := range files {
+			if f == nil {
+				continue
+			}
+			if rule.maxSize > 0 && f.Size > rule.maxSize {
+				return code.NewCodeError(http.StatusRequestEntityTooLarge, "file %s size %d exceeds limit %d", rule.name, f.Size, rule.maxSize)
+			}
+			if len(rule.mimes) > 0 {
+				ct, err := sniffContentType(f)
+				if err != nil {
+					return err
+				}
+				ok := matchMIME(ct, rule.mimes)
+				// json/csv等无法通过内容识别 只有这些类型退回使用客户端提交的类型
+				// 图片 pdf等可以识别的类型必须以内容为准
+				if !ok && (ct == "application/octet-stream" || ct == "text/plain") {
+					declared, _, _ := mime.ParseMediaType(f.Header.Get("Content-Type"))
+					ok = declared != "" && !sniffable(declared) && matchMIME(declared, rule.mimes)
+				}
+				if !ok {
+					return code.NewCodeError(http.StatusUnsupportedMediaType, "file %s content type %s not allowed", rule.name, ct)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func sniffContentType(f File) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	ct, _, _ := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	return ct, nil
+}
+
+// sniffableTypes http.DetectContentType可以识别的类型
+var sniffableTypes = map[string]bool{
+	"text/plain":                    true,
+	"text/html":                     true,
+	"text/xml":                      true,
+	"application/pdf":               true,
+	"application/postscript":        true,
+	"application/ogg":               true,
+	"application/zip":               true,
+	"application/x-gzip":            true,
+	"application/gzip":              true,
+	"application/x-rar-compressed":  true,
+	"application/wasm":              true,
+	"application/vnd.ms-fontobject": true,
+}
+
+// sniffable 是否可以通过内容识别
+func sniffable(ct string) bool {
+	if sniffableTypes[ct] {
+		return true
+	}
+	kind, _, _ := strings.Cut(ct, "/")
+	switch kind {
+	case "image", "audio", "video", "font":
+		return true
+	}
+	return false
+}
+
+func matchMIME(ct string, allows []string) bool {
+	for _, a := range allows {
+		a = strings.TrimSpace(a)
+		if a == ct || a == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(ct, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseByteSize 解析 1024 / 512KB / 10MB / 1GB 格式的大小
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		n      int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+	for _, u := range units {
+		if v, ok := strings.CutSuffix(s, u.suffix); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return n * u.n, err
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
@@ -0,0 +1,139 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0000")
+
+type uploadRequest struct {
+	Name   string     `form:"name"`
+	Avatar web.File   `form:"avatar" binding:"required" file:"maxsize=1KB,mime=image/png"`
+	Docs   []web.File `form:"docs" file:"mime=text/plain"`
+	Data   web.File   `form:"data" file:"mime=application/json|image/*"`
+}
+
+type uploadResponse struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Files int    `json:"files"`
+}
+
+// uploadFile 上传的文件 contentType为客户端声明的类型
+type uploadFile struct {
+	field, contentType string
+	data               []byte
+}
+
+// multipartBody 生成上传的请求体
+func multipartBody(t *testing.T, fields map[string]string, files ...uploadFile) (string, io.Reader) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	for _, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s.bin"`, f.field, f.field))
+		h.Set("Content-Type", f.contentType)
+		fw, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(f.data)
+	}
+	w.Close()
+	return w.FormDataContentType(), &buf
+}
+
+func TestFileUpload(t *testing.T) {
+	h := newTestServer(t, func(r web.Router) {
+		r.Post("/upload", func(ctx context.Context, in *uploadRequest) (*uploadResponse, error) {
+			return &uploadResponse{Name: in.Name, Size: in.Avatar.Size, Files: len(in.Docs)}, nil
+		})
+	})
+	upload := func(status int, files ...uploadFile) *httptest.ResponseRecorder {
+		t.Helper()
+		ct, body := multipartBody(t, map[string]string{"name": "bob"}, files...)
+		w := do(h, http.MethodPost, "/upload", body, "Content-Type", ct)
+		if w.Code != status {
+			t.Fatalf("expect %d, got %d %s", status, w.Code, w.Body)
+		}
+		return w
+	}
+	avatar := func(data []byte, contentType string) uploadFile {
+		return uploadFile{"avatar", contentType, data}
+	}
+	png := avatar(pngHeader, "application/octet-stream")
+
+	var out uploadResponse
+	w := upload(http.StatusOK, png, uploadFile{"docs", "text/plain", []byte("hello")}, uploadFile{"docs", "text/plain", []byte("world")})
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Name != "bob" || out.Size != int64(len(pngHeader)) || out.Files != 2 {
+		t.Fatalf("unexpected %+v %v", out, err)
+	}
+	upload(http.StatusBadRequest)
+	// 类型通过文件内容判断
+	if w := upload(http.StatusUnsupportedMediaType, avatar([]byte("GIF89a"), "image/png")); !strings.Contains(w.Body.String(), "not allowed") {
+		t.Fatalf("unexpected %s", w.Body)
+	}
+	// 可以识别的类型不使用客户端声明的类型
+	upload(http.StatusUnsupportedMediaType, avatar([]byte("hello"), "image/png"))
+	upload(http.StatusUnsupportedMediaType, png, uploadFile{"data", "image/png", []byte("plain text")})
+	// 无法识别的类型使用客户端声明的类型
+	upload(http.StatusOK, png, uploadFile{"data", "application/json", []byte(`{"a":1}`)})
+	upload(http.StatusUnsupportedMediaType, png, uploadFile{"data", "text/csv", []byte("a,b")})
+	if w := upload(http.StatusRequestEntityTooLarge, avatar(append(pngHeader, bytes.Repeat([]byte{0}, 2<<10)...), "image/png")); !strings.Contains(w.Body.String(), "exceeds limit 1024") {
+		t.Fatalf("unexpected %s", w.Body)
+	}
+}
+
+type downloadRequest struct {
+	Inline bool `form:"inline"`
+}
+
+func TestFileResponse(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	h := newTestServer(t, func(r web.Router) {
+		r.Get("/download", func(ctx context.Context, in *downloadRequest) (*web.FileResponse, error) {
+			return &web.FileResponse{
+				Name:    "报表.txt",
+				ModTime: modTime,
+				Content: strings.NewReader("0123456789"),
+				Inline:  in.Inline,
+			}, nil
+		})
+	})
+
+	w := do(h, http.MethodGet, "/download", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" || !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment; filename*=utf-8''") {
+		t.Fatalf("unexpected download %d %v %s", w.Code, w.Header(), w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("content type %s", ct)
+	}
+	if cd := do(h, http.MethodGet, "/download?inline=true", nil).Header().Get("Content-Disposition"); cd != "inline; filename*=utf-8''%E6%8A%A5%E8%A1%A8.txt" {
+		t.Fatalf("unexpected %s", cd)
+	}
+
+	// 断点续传
+	w = do(h, http.MethodGet, "/download", nil, "Range", "bytes=2-4")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "234" {
+		t.Fatalf("range %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodGet, "/download", nil, "If-Modified-Since", modTime.Format(http.TimeFormat)); w.Code != http.StatusNotModified {
+		t.Fatalf("expect 304, got %d", w.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package web_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/http/web"
+)
+
+// testUser 测试中共用的响应结构 同时支持json/xml/yaml
+type testUser struct {
+	XMLName xml.Name `json:"-" xml:"user" yaml:"-"`
+	ID      int64    `json:"id" xml:"id" yaml:"id"`
+	Name    string   `json:"name" xml:"name" yaml:"name"`
+}
+
+type testUserRequest struct {
+	ID int64 `uri:"id"`
+}
+
+// getUser id为404/500时分别返回未找到和内部错误
+func getUser(ctx context.Context, in *testUserRequest) (*testUser, error) {
+	switch in.ID {
+	case 404:
+		return nil, code.NewNotfoundError("user not found")
+	case 500:
+		return nil, errors.New("db down")
+	}
+	return &testUser{ID: in.ID, Name: "igo"}, nil
+}
+
+// newTestServer 创建服务并注册路由 返回可以直接处理请求的handler
+func newTestServer(t *testing.T, setup func(r web.Router), opts ...web.Option) http.Handler {
+	t.Helper()
+	srv := web.New(append([]web.Option{web.WithPProf(false)}, opts...)...)
+	setup(srv.Router())
+	return srv.GinEngine()
+}
+
+// do 在进程内执行请求 header按key value成对传入
+func do(h http.Handler, method, target string, body io.Reader, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"errors"
+	"sync"
+)
+
+// Hub websocket连接分组 用于向一组连接广播消息
+// 连接关闭后会自动从所有分组中移除
+type Hub struct {
+	mu     sync.RWMutex
+	groups map[string]map[*WSConn]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		groups: make(map[string]map[*WSConn]struct{}),
+	}
+}
+
+// Join 将连接加入分组
+func (h *Hub) Join(group string, conn *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.groups[group]
+	if !ok {
+		g = make(map[*WSConn]struct{})
+		h.groups[group] = g
+	}
+	if _, ok := g[conn]; ok {
+		return
+	}
+	if conn.addCloseHook(func(c *WSConn) { h.Leave(group, c) }) {
+		g[conn] = struct{}{}
+	} else if len(g) == 0 {
+		delete(h.groups, group)
+	}
+}
+
+// Leave 将连接移出分组
+func (h *Hub) Leave(group string, conn *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if g, ok := h.groups[group]; ok {
+		delete(g, conn)
+		if len(g) == 0 {
+			delete(h.groups, group)
+		}
+	}
+}
+
+// Count 分组内的连接数
+func (h *Hub) Count(group string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.groups[group])
+}
+
+// Broadcast 向分组内所有连接发送消息
+// 单个连接发送失败不影响其他连接 返回所有失败的错误
+func (h *Hub) Broadcast(group string, v any) error {
+	h.mu.RLock()
+	conns := make([]*WSConn, 0, len(h.groups[group]))
+	for c := range h.groups[group] {
+		conns = append(conns, c)
+	}
+	h.mu.RUnlock()
+
+	var errs []error
+	for _, c := range conns {
+		if err := c.WriteJSON(v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,274 @@
+// Package idempotency 基于redis的幂等处理
+// 相同 Idempotency-Key 的重复请求直接返回第一次的响应 不会重复执行
+//
+//	srv.Router().Post("/pay", idempotency.New(redis.Get(), idempotency.Config{}), pay)
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/auth"
+	"github.com/parkingwang/igo/pkg/requestid"
+)
+
+const (
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed 重放的响应会带上此响应头
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+// Config 幂等配置
+type Config struct {
+	// 响应的保存时间 默认24小时
+	TTL time.Duration
+	// 处理中的锁定时间 超过后允许重试 默认1分钟 应大于接口的超时时间
+	LockTTL time.Duration
+	// 是否必须携带 Idempotency-Key 默认不携带时不做处理
+	Required bool
+	// redis key前缀 默认 igo:idempotency:
+	Prefix string
+	// 可以保存的最大响应体 超过时不保存 默认1MB
+	MaxBodySize int
+	// 计算摘要时读取的最大请求体 超过时返回413 默认32MB
+	MaxRequestSize int64
+	// 区分调用方 不同调用方使用相同的key互不影响 默认使用认证后的auth.Principal
+	Scope func(c *gin.Context) string
+}
+
+const (
+	statusProcessing = "processing"
+	statusDone       = "done"
+)
+
+// record 保存在redis中的记录
+type record struct {
+	Status string `json:"status"`
+	// 请求的摘要 用于检测相同key不同的请求参数
+	Fingerprint string      `json:"fingerprint"`
+	Code        int         `json:"code,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// New 创建幂等中间件 需要放在rpc方法之前
+// 会先执行路由声明的认证和权限验证 重放的响应只返回给相同的调用方
+// 5xx以及认证/限流等未执行业务的响应不会保存 客户端可以使用相同的key重试
+func New(client goredis.Cmdable, cfg Config) gin.HandlerFunc {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Hour * 24
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = time.Minute
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "igo:idempotency:"
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	if cfg.MaxRequestSize <= 0 {
+		cfg.MaxRequestSize = 32 << 20
+	}
+	if cfg.Scope == nil {
+		cfg.Scope = principalScope
+	}
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderKey)
+		if key == "" {
+			if cfg.Required {
+				web.Abort(c, code.NewBadRequestError("missing "+HeaderKey+" header"))
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			web.Abort(c, code.NewBadRequestError(HeaderKey+" too long"))
+			return
+		}
+		// 认证失败的请求不能读取其他调用方保存的响应
+		if err := web.Guard(c); err != nil {
+			web.Abort(c, err)
+			return
+		}
+		fingerprint, err := requestFingerprint(c, cfg.MaxRequestSize)
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				web.Abort(c, code.NewCodeError(http.StatusRequestEntityTooLarge, "request body too large, limit %d bytes", mbe.Limit))
+				return
+			}
+			web.Abort(c, code.NewBadRequestError(err))
+			return
+		}
+		// 不同的调用方和路由使用相同的key互不影响
+		rkey := cfg.Prefix + scopeKey(cfg.Scope(c)) + ":" + c.Request.Method + ":" + c.FullPath() + ":" + key
+
+		lock, _ := json.Marshal(record{Status: statusProcessing, Fingerprint: fingerprint})
+		ok, err := client.SetNX(c, rkey, lock, cfg.LockTTL).Result()
+		if err != nil {
+			slog.ErrorContext(c, "idempotency: lock failed", slog.String("key", key), slog.String("err", err.Error()))
+			web.Abort(c, code.NewCodeError(http.StatusServiceUnavailable, "idempotency store unavailable"))
+			return
+		}
+		if !ok {
+			replay(c, client, rkey, fingerprint)
+			return
+		}
+
+		w := &recorder{ResponseWriter: c.Writer, limit: cfg.MaxBodySize}
+		c.Writer = w
+		// 客户端断开后仍需要保存结果
+		ctx := context.WithoutCancel(c.Request.Context())
+		// panic时也需要释放锁 允许客户端重试
+		completed := false
+		defer func() {
+			if !completed {
+				client.Del(ctx, rkey)
+			}
+		}()
+		c.Next()
+
+		status := w.Status()
+		if !cacheable(status) || w.overflow {
+			return
+		}
+		b, _ := json.Marshal(record{
+			Status:      statusDone,
+			Fingerprint: fingerprint,
+			Code:        status,
+			Header:      w.Header().Clone(),
+			Body:        w.buf.Bytes(),
+		})
+		if err := client.Set(ctx, rkey, b, cfg.TTL).Err(); err != nil {
+			slog.ErrorContext(c, "idempotency: save response failed", slog.String("key", key), slog.String("err", err.Error()))
+			return
+		}
+		completed = true
+	}
+}
+
+// cacheable 是否保存响应
+func cacheable(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout,
+		http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// replay 重复的请求 输出保存的响应
+func replay(c *gin.Context, client goredis.Cmdable, rkey, fingerprint string) {
+	b, err := client.Get(c, rkey).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		// 刚好被释放 让客户端重试
+		web.Abort(c, code.NewCodeError(http.StatusConflict, "request is being processed, retry later"))
+		return
+	}
+	if err != nil {
+		web.Abort(c, code.NewCodeError(http.StatusServiceUnavailable, "idempotency store unavailable"))
+		return
+	}
+	var rec record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		web.Abort(c, err)
+		return
+	}
+	if rec.Fingerprint != fingerprint {
+		web.Abort(c, code.NewCodeError(http.StatusUnprocessableEntity, "%s reused with different request payload", HeaderKey))
+		return
+	}
+	if rec.Status != statusDone {
+		web.Abort(c, code.NewCodeError(http.StatusConflict, "request is being processed, retry later"))
+		return
+	}
+	c.Abort()
+	h := c.Writer.Header()
+	for k, v := range rec.Header {
+		h[k] = v
+	}
+	// 保留当前请求的ID
+	if id := requestid.FromContext(c); id != "" {
+		h.Set(requestid.Header, id)
+	}
+	h.Set(HeaderReplayed, "true")
+	c.Status(rec.Code)
+	c.Writer.Write(rec.Body)
+}
+
+// principalScope 认证后的身份 未认证的请求为空
+func principalScope(c *gin.Context) string {
+	if p, ok := auth.FromContext(c.Request.Context()); ok {
+		return p.Scheme + ":" + p.Subject
+	}
+	return ""
+}
+
+// scopeKey 调用方标识的摘要 避免redis key中出现任意字符
+func scopeKey(scope string) string {
+	if scope == "" {
+		return "-"
+	}
+	sum := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(sum[:16])
+}
+
+// requestFingerprint 请求方法 路径 参数和请求体的摘要
+func requestFingerprint(c *gin.Context, limit int64) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+" "+c.Request.URL.Path+"?"+c.Request.URL.RawQuery+"\n")
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recorder 记录输出的响应体
+type recorder struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	w.record(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recorder) record(b []byte) {
+	if w.overflow {
+		return
+	}
+	if w.buf.Len()+len(b) > w.limit {
+		w.overflow = true
+		w.buf.Reset()
+		return
+	}
+	w.buf.Write(b)
+}
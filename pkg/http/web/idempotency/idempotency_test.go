@@ -0,0 +1,101 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/auth"
+)
+
+func TestIdempotency(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	var calls int
+	e := gin.New()
+	e.POST("/pay", New(client, Config{}), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"n": calls})
+	})
+	do := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/pay", strings.NewReader(body))
+		r.Header.Set(HeaderKey, key)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w
+	}
+
+	first := do("k1", `{"amount":1}`)
+	second := do("k1", `{"amount":1}`)
+	if calls != 1 || second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Fatalf("expect replay, calls %d code %d body %s", calls, second.Code, second.Body)
+	}
+	if second.Header().Get(HeaderReplayed) != "true" {
+		t.Fatal("expect replayed header")
+	}
+	if w := do("k1", `{"amount":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expect payload mismatch, got %d", w.Code)
+	}
+	if do("k2", `{"amount":1}`); calls != 2 {
+		t.Fatalf("expect new key processed, calls %d", calls)
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	a, _ := auth.NewAPIKey(auth.APIKeyConfig{Keys: []auth.Credential{
+		{ID: "tenant1", Secret: "k1"},
+		{ID: "tenant2", Secret: "k2"},
+	}})
+	var calls int
+	srv := web.New(web.WithAuthenticator("apikey", a))
+	// 分组的中间件在rpc方法之前执行
+	pay := srv.Router().Group("/pay", New(client, Config{MaxRequestSize: 64}))
+	pay.Auth("apikey")
+	pay.Post("/order", func(ctx context.Context, in *struct {
+		Amount int `json:"amount"`
+	}) (int, error) {
+		calls++
+		return calls, nil
+	})
+
+	do := func(key, apikey, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/pay/order", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(HeaderKey, key)
+		if apikey != "" {
+			r.Header.Set("X-API-Key", apikey)
+		}
+		w := httptest.NewRecorder()
+		srv.GinEngine().ServeHTTP(w, r)
+		return w
+	}
+	// 未认证的请求不能读取保存的响应
+	if w := do("k1", "k1", `{"amount":1}`); w.Code != http.StatusOK {
+		t.Fatalf("expect ok, got %d", w.Code)
+	}
+	if w := do("k1", "", `{"amount":1}`); w.Code != http.StatusUnauthorized || w.Header().Get(HeaderReplayed) != "" {
+		t.Fatalf("expect unauthorized, got %d", w.Code)
+	}
+	// 不同的调用方使用相同的key互不影响
+	if w := do("k1", "k2", `{"amount":1}`); w.Code != http.StatusOK || w.Body.String() != "2" {
+		t.Fatalf("expect processed for another caller, got %d %s", w.Code, w.Body)
+	}
+	if w := do("k1", "k1", `{"amount":1}`); w.Header().Get(HeaderReplayed) != "true" || w.Body.String() != "1" {
+		t.Fatalf("expect replayed, got %v %s", w.Header(), w.Body)
+	}
+	if calls != 2 {
+		t.Fatalf("expect 2 calls, got %d", calls)
+	}
+
+	if w := do("k2", "k1", `{"amount":1,"memo":"`+strings.Repeat("x", 64)+`"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413, got %d", w.Code)
+	}
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/code"
+)
+
+// provider 为rpc方法的额外参数提供值
+type provider func(c *gin.Context) (reflect.Value, error)
+
+var rtypeGinContext = reflect.TypeOf(&gin.Context{})
+
+// WithValues 注册rpc方法可以声明的参数 按类型匹配 所有请求使用同一个值
+// igo.Application.ProvideHandlerValue 注册的对象由CreateWebServer自动传入 也可以手动传入fx注入的对象
+//
+//	app.Run(func(db *gorm.DB) igo.Servicer {
+//		srv := app.CreateWebServer(web.WithValues(db))
+//		srv.Router().Get("/user/:id", func(ctx context.Context, in *In, db *gorm.DB) (*User, error) {...})
+//		return srv
+//	})
+func WithValues(vs ...any) Option {
+	return func(o *option) {
+		for _, v := range vs {
+			rv := reflect.ValueOf(v)
+			o.providers[rv.Type()] = func(*gin.Context) (reflect.Value, error) {
+				return rv, nil
+			}
+		}
+	}
+}
+
+// WithProvider 注册请求级别的参数 每次请求调用
+// 格式为 func(context.Context) (T, error) 返回 *code.CodeError 时原样输出
+//
+//	web.WithProvider(func(ctx context.Context) (*gorm.DB, error) {
+//		return database.Get(ctx), nil
+//	})
+func WithProvider(fs ...any) Option {
+	return func(o *option) {
+		for _, f := range fs {
+			fv := reflect.ValueOf(f)
+			tp := fv.Type()
+			if tp.Kind() != reflect.Func || tp.NumIn() != 1 || !rtypeContext.Implements(tp.In(0)) ||
+				tp.NumOut() != 2 || !rtypeError.Implements(tp.Out(1)) {
+				panic(fmt.Sprintf("provider must func(context.Context) (T, error), got %s", tp))
+			}
+			o.providers[tp.Out(0)] = func(c *gin.Context) (reflect.Value, error) {
+				ret := fv.Call([]reflect.Value{reflect.ValueOf(c)})
+				if err, _ := ret[1].Interface().(error); err != nil {
+					return reflect.Value{}, err
+				}
+				return ret[0], nil
+			}
+		}
+	}
+}
+
+// findProvider 优先匹配相同的类型 参数为接口时使用唯一实现了此接口的类型
+func (o *option) findProvider(t reflect.Type) (provider, error) {
+	if t == rtypeGinContext {
+		return func(c *gin.Context) (reflect.Value, error) {
+			return reflect.ValueOf(c), nil
+		}, nil
+	}
+	if p, ok := o.providers[t]; ok {
+		return p, nil
+	}
+	var found []reflect.Type
+	if t.Kind() == reflect.Interface {
+		for pt := range o.providers {
+			if pt.Implements(t) {
+				found = append(found, pt)
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no provider for %s, register by web.WithValues or web.WithProvider", t)
+	case 1:
+		return o.providers[found[0]], nil
+	default:
+		return nil, fmt.Errorf("ambiguous providers %v for %s", found, t)
+	}
+}
+
+// handlerProviders rpc方法第三个参数开始的依赖
+func handlerProviders(opt *option, tp reflect.Type) []provider {
+	list := make([]provider, 0, tp.NumIn()-2)
+	for i := 2; i < tp.NumIn(); i++ {
+		p, err := opt.findProvider(tp.In(i))
+		if err != nil {
+			panic(err)
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
+// resolveArgs 请求时获取依赖 非CodeError按500输出
+func resolveArgs(c *gin.Context, providers []provider, args []reflect.Value) ([]reflect.Value, error) {
+	for _, p := range providers {
+		v, err := p(c)
+		if err != nil {
+			var ce *code.CodeError
+			if !errors.As(err, &ce) {
+				err = fmt.Errorf("resolve handler dependency: %w", err)
+			}
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
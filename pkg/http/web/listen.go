@@ -0,0 +1,249 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"log/slog"
+)
+
+// WithListen 监听地址 同一个服务可以同时监听多个地址 设置后忽略WithAddr
+//
+//	:8080 / tcp://0.0.0.0:8080      tcp
+//	tcp://127.0.0.1:9090?tls=off     启用tls时 此地址仍使用http
+//	unix:///run/app.sock?mode=0660   unix socket 不使用tls
+//	fd://3                           继承的文件描述符
+//	systemd:// / systemd://name      systemd socket activation 全部或指定名称(FileDescriptorName)的fd
+func WithListen(specs ...string) Option {
+	return func(o *option) {
+		o.listens = append(o.listens, specs...)
+	}
+}
+
+// listener 监听的地址及是否使用tls
+type listener struct {
+	net.Listener
+	spec string
+	tls  bool
+}
+
+// listenAll 创建所有监听 任意一个失败时关闭已经创建的
+func listenAll(specs []string, useTLS bool) ([]listener, error) {
+	var ls []listener
+	for _, spec := range specs {
+		items, err := listen(spec, useTLS)
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+			return nil, fmt.Errorf("listen %s: %w", spec, err)
+		}
+		ls = append(ls, items...)
+	}
+	return ls, nil
+}
+
+func listen(spec string, useTLS bool) ([]listener, error) {
+	if !strings.Contains(spec, "://") {
+		spec = "tcp://" + spec
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if u.Query().Get("tls") == "off" || u.Scheme == "unix" {
+		useTLS = false
+	}
+	if fds, ok := inheritedFds(spec); ok {
+		return fileListeners(fds, spec, useTLS)
+	}
+	var l net.Listener
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6":
+		l, err = net.Listen(u.Scheme, u.Host)
+	case "unix":
+		l, err = listenUnix(u.Path, u.Query().Get("mode"))
+	case "fd":
+		fd, err := strconv.Atoi(u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fd %q", u.Host)
+		}
+		return fileListeners([]int{fd}, spec, useTLS)
+	case "systemd":
+		fds, err := systemdFds(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return fileListeners(fds, spec, useTLS)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []listener{{l, spec, useTLS}}, nil
+}
+
+func fileListeners(fds []int, spec string, useTLS bool) ([]listener, error) {
+	ls := make([]listener, 0, len(fds))
+	for _, fd := range fds {
+		l, err := fileListener(uintptr(fd), spec)
+		if err != nil {
+			for _, v := range ls {
+				v.Close()
+			}
+			return nil, err
+		}
+		ls = append(ls, listener{l, spec, useTLS})
+	}
+	return ls, nil
+}
+
+// listenUnix 删除上次未清理的socket文件后监听
+func listenUnix(path, mode string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+		// 仍有进程在监听时不能删除
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, errors.New("address already in use")
+		}
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != "" {
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("invalid mode %q", mode)
+		}
+		if err := os.Chmod(path, fs.FileMode(m)); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func fileListener(fd uintptr, name string) (net.Listener, error) {
+	f := os.NewFile(fd, name)
+	if f == nil {
+		return nil, fmt.Errorf("invalid fd %d", fd)
+	}
+	// FileListener会复制fd
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// systemd socket activation 传递的fd从3开始
+const listenFdsStart = 3
+
+var (
+	systemdOnce  sync.Once
+	systemdNames []string
+	systemdErr   error
+)
+
+// systemdFds 读取 LISTEN_FDS 和 LISTEN_FDNAMES name为空时返回全部
+func systemdFds(name string) ([]int, error) {
+	systemdOnce.Do(func() {
+		if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != os.Getpid() {
+			systemdErr = errors.New("LISTEN_PID does not match current process")
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n <= 0 {
+			systemdErr = errors.New("LISTEN_FDS not set")
+			return
+		}
+		names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+		systemdNames = make([]string, n)
+		for i := range systemdNames {
+			if i < len(names) {
+				systemdNames[i] = names[i]
+			}
+		}
+	})
+	if systemdErr != nil {
+		return nil, systemdErr
+	}
+	var fds []int
+	for i, n := range systemdNames {
+		if name == "" || n == name {
+			fds = append(fds, listenFdsStart+i)
+		}
+	}
+	if len(fds) == 0 {
+		return nil, fmt.Errorf("no systemd socket named %q", name)
+	}
+	return fds, nil
+}
+
+// EnvInheritListeners 平滑重启时父进程传递的监听 json格式 {"监听地址": [fd]}
+const EnvInheritListeners = "IGO_INHERIT_LISTENERS"
+
+var (
+	inheritOnce sync.Once
+	inherited   map[string][]int
+	inheritMu   sync.Mutex
+)
+
+// inheritedFds 返回父进程传递的监听地址对应的fd 每个地址只能使用一次
+func inheritedFds(spec string) ([]int, bool) {
+	inheritOnce.Do(func() {
+		if v := os.Getenv(EnvInheritListeners); v != "" {
+			if err := json.Unmarshal([]byte(v), &inherited); err != nil {
+				slog.Error("parse inherited listeners failed", slog.String("err", err.Error()))
+			}
+			os.Unsetenv(EnvInheritListeners)
+		}
+	})
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+	fds, ok := inherited[spec]
+	delete(inherited, spec)
+	return fds, ok
+}
+
+// ListenerFiles 返回正在监听的地址和对应的文件 用于平滑重启时传递给新进程
+// 新进程就绪后需要调用CommitHandoff
+func (s *Server) ListenerFiles() ([]string, []*os.File, error) {
+	specs := make([]string, 0, len(s.listeners))
+	files := make([]*os.File, 0, len(s.listeners))
+	for _, l := range s.listeners {
+		fl, ok := l.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+		f, err := fl.File()
+		if err != nil {
+			for _, v := range files {
+				v.Close()
+			}
+			return nil, nil, err
+		}
+		specs = append(specs, l.spec)
+		files = append(files, f)
+	}
+	return specs, files, nil
+}
+
+// CommitHandoff 新进程已经接管监听 之后unix socket关闭时不再删除socket文件
+// 新进程启动失败时不调用 当前进程退出时仍会清理socket文件
+func (s *Server) CommitHandoff() {
+	for _, l := range s.listeners {
+		if ul, ok := l.Listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+}
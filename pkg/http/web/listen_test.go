@@ -0,0 +1,224 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func closeListeners(ls []listener) {
+	for _, l := range ls {
+		l.Close()
+	}
+}
+
+func TestListen(t *testing.T) {
+	ls, err := listen("127.0.0.1:0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListeners(ls)
+	if len(ls) != 1 || !ls[0].tls || ls[0].spec != "tcp://127.0.0.1:0" {
+		t.Fatalf("unexpected %+v", ls)
+	}
+
+	// tls=off 以及unix socket 不使用tls
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	for _, spec := range []string{"tcp://127.0.0.1:0?tls=off", "unix://" + sock} {
+		ls, err := listen(spec, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		closeListeners(ls)
+		if ls[0].tls {
+			t.Errorf("%s should not use tls", spec)
+		}
+	}
+
+	for _, spec := range []string{"udp://127.0.0.1:0", "fd://abc", "unix://" + sock + "?mode=999"} {
+		if _, err := listen(spec, false); err == nil {
+			t.Errorf("%s: expect error", spec)
+		}
+	}
+	// 任意一个失败时返回错误
+	if _, err := listenAll([]string{"127.0.0.1:0", "udp://127.0.0.1:0"}, false); err == nil || !strings.Contains(err.Error(), "udp://") {
+		t.Fatalf("expect listenAll failed on udp: %v", err)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	ls, err := listen("unix://"+sock+"?mode=0660", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o660 {
+		t.Fatalf("expect mode 0660, got %o", perm)
+	}
+
+	// 仍在监听时不能删除
+	if _, err := listen("unix://"+sock, false); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("expect address in use: %v", err)
+	}
+
+	// 上次未清理的socket文件
+	ls[0].Listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	closeListeners(ls)
+	if _, err := os.Stat(sock); err != nil {
+		t.Fatalf("socket file should be left: %v", err)
+	}
+	ls, err = listen("unix://"+sock, false)
+	if err != nil {
+		t.Fatalf("expect stale socket removed: %v", err)
+	}
+	closeListeners(ls)
+}
+
+func TestListenFd(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ls, err := listen(fmt.Sprintf("fd://%d", f.Fd()), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListeners(ls)
+	if ls[0].Addr().String() != l.Addr().String() {
+		t.Fatalf("expect same address %s, got %s", l.Addr(), ls[0].Addr())
+	}
+}
+
+func TestServeMultiListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	srv := New(WithPProf(false), WithListen("127.0.0.1:0", "unix://"+sock))
+	srv.Router().Get("/ping", func(ctx context.Context, in *Empty) (string, error) {
+		return "pong", nil
+	})
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+	if len(srv.listeners) != 2 {
+		t.Fatalf("expect 2 listeners, got %d", len(srv.listeners))
+	}
+
+	get := func(client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+	if body := get(http.DefaultClient, "http://"+srv.listeners[0].Addr().String()+"/ping"); body != `"pong"` {
+		t.Fatalf("tcp: %s", body)
+	}
+	unix := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+	if body := get(unix, "http://unix/ping"); body != `"pong"` {
+		t.Fatalf("unix: %s", body)
+	}
+}
+
+func TestListenerFilesNotCommitted(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	ls, err := listen("unix://"+sock, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, files, err := (&Server{listeners: ls}).ListenerFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		f.Close()
+	}
+	// 新进程没有就绪 关闭时仍然删除socket文件
+	closeListeners(ls)
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Fatalf("socket file should be removed: %v", err)
+	}
+}
+
+func TestInheritListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	specs := []string{"tcp://127.0.0.1:0", "unix://" + sock}
+	parent, err := listenAll(specs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{listeners: parent}
+	names, files, err := srv.ListenerFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	// 交接后父进程关闭监听不能删除socket文件
+	srv.CommitHandoff()
+	closeListeners(parent)
+	if _, err := os.Stat(sock); err != nil {
+		t.Fatalf("socket file removed after handoff: %v", err)
+	}
+
+	var env []string
+	for i, name := range names {
+		env = append(env, fmt.Sprintf("%q:[%d]", name, files[i].Fd()))
+	}
+	t.Setenv(EnvInheritListeners, "{"+strings.Join(env, ",")+"}")
+	inheritOnce, inherited = sync.Once{}, nil
+
+	child, err := listenAll(specs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListeners(child)
+	if os.Getenv(EnvInheritListeners) != "" {
+		t.Fatal("inherited listeners should be cleared from env")
+	}
+	if child[0].Addr().String() != parent[0].Addr().String() {
+		t.Fatalf("expect inherited tcp %s, got %s", parent[0].Addr(), child[0].Addr())
+	}
+	c, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("inherited unix socket not accepting: %v", err)
+	}
+	c.Close()
+
+	// 每个地址只能继承一次 再次监听时创建新的
+	again, err := listen(specs[0], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListeners(again)
+	if again[0].Addr().String() == parent[0].Addr().String() {
+		t.Fatal("inherited fd should be used only once")
+	}
+}
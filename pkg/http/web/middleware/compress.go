@@ -0,0 +1,287 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// CompressionConfig 响应压缩配置
+type CompressionConfig struct {
+	// 支持的算法 按顺序优先 可选 br gzip 默认 ["br", "gzip"]
+	Algorithms []string
+	// 压缩等级 0使用各算法的默认值
+	Level int
+	// 小于此大小的响应不压缩 默认1024
+	MinSize int
+	// 需要压缩的Content-Type 支持 text/* 前缀匹配
+	// 默认 text/* application/json application/xml application/javascript application/x-yaml
+	ContentTypes []string
+}
+
+// Compression 根据 Accept-Encoding 压缩响应
+// SSE/websocket/已经设置了Content-Encoding的响应不处理
+func Compression(cfg CompressionConfig) gin.HandlerFunc {
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{"br", "gzip"}
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1024
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = []string{
+			"text/*",
+			"application/json",
+			"application/xml",
+			"application/javascript",
+			"application/x-yaml",
+		}
+	}
+	encoders := make(map[string]*sync.Pool)
+	for _, name := range cfg.Algorithms {
+		if p := newEncoderPool(name, cfg.Level); p != nil {
+			encoders[name] = p
+		}
+	}
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), cfg.Algorithms)
+		if encoding == "" || encoders[encoding] == nil {
+			c.Next()
+			return
+		}
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			cfg:            &cfg,
+			encoding:       encoding,
+			pool:           encoders[encoding],
+			status:         http.StatusOK,
+		}
+		// 压缩后的ETag带有算法后缀 还原后交给后续的条件请求处理
+		if inm := c.GetHeader("If-None-Match"); inm != "" {
+			if v := strings.ReplaceAll(inm, etagSuffix(encoding)+`"`, `"`); v != inm {
+				c.Request.Header.Set("If-None-Match", v)
+				w.conditional = true
+			}
+		}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+func newEncoderPool(name string, level int) *sync.Pool {
+	switch name {
+	case "gzip":
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return &sync.Pool{New: func() any {
+			w, err := gzip.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				w = gzip.NewWriter(io.Discard)
+			}
+			return w
+		}}
+	case "br":
+		if level == 0 {
+			// 默认等级11太慢 不适合动态内容
+			level = 4
+		}
+		return &sync.Pool{New: func() any {
+			return brotli.NewWriterLevel(io.Discard, level)
+		}}
+	}
+	return nil
+}
+
+// negotiateEncoding 选择客户端支持的第一个算法 忽略q=0
+func negotiateEncoding(accept string, algorithms []string) string {
+	if accept == "" {
+		return ""
+	}
+	supported := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		supported[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+	for _, name := range algorithms {
+		if supported[name] || (supported["*"] && !hasKey(supported, name)) {
+			return name
+		}
+	}
+	return ""
+}
+
+func hasKey(m map[string]bool, k string) bool {
+	_, ok := m[k]
+	return ok
+}
+
+// compressWriter 缓冲到MinSize后决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      *CompressionConfig
+	encoding string
+	pool     *sync.Pool
+	status   int
+	buf      []byte
+	decided  bool
+	enc      encoder
+	// 请求的If-None-Match是压缩后的ETag
+	conditional bool
+}
+
+func etagSuffix(encoding string) string {
+	return "-" + encoding
+}
+
+// alterETag 同一资源压缩前后是不同的表示 强ETag需要区分
+func (w *compressWriter) alterETag(h http.Header) {
+	etag := h.Get("ETag")
+	if strings.HasSuffix(etag, `"`) && !strings.HasSuffix(etag, etagSuffix(w.encoding)+`"`) {
+		h.Set("ETag", etag[:len(etag)-1]+etagSuffix(w.encoding)+`"`)
+	}
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided && code > 0 {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.cfg.MinSize {
+			w.decide(true)
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Status() int {
+	if !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.decided || len(w.buf) > 0
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide 输出响应头 决定是否压缩
+func (w *compressWriter) decide(large bool) {
+	w.decided = true
+	h := w.Header()
+	if large && w.compressible(h) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		h.Add("Vary", "Accept-Encoding")
+		w.alterETag(h)
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	} else if w.status == http.StatusNotModified && w.conditional {
+		w.alterETag(h)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if len(w.buf) > 0 {
+		if w.enc != nil {
+			w.enc.Write(w.buf)
+		} else {
+			w.ResponseWriter.Write(w.buf)
+		}
+		w.buf = nil
+	}
+}
+
+func (w *compressWriter) compressible(h http.Header) bool {
+	if h.Get("Content-Encoding") != "" || w.status < http.StatusOK ||
+		w.status == http.StatusNoContent || w.status == http.StatusNotModified ||
+		w.status == http.StatusPartialContent {
+		return false
+	}
+	ct, _, _ := strings.Cut(h.Get("Content-Type"), ";")
+	ct = strings.TrimSpace(strings.ToLower(ct))
+	if ct == "" || ct == "text/event-stream" {
+		return false
+	}
+	for _, v := range w.cfg.ContentTypes {
+		if prefix, ok := strings.CutSuffix(v, "*"); ok {
+			if strings.HasPrefix(ct, prefix) {
+				return true
+			}
+		} else if ct == v {
+			return true
+		}
+	}
+	return false
+}
+
+// finish 请求结束 输出剩余的数据
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if len(w.buf) == 0 && !w.ResponseWriter.Written() && w.status == http.StatusOK {
+			// 没有任何输出 保持gin的默认行为
+			w.decided = true
+			return
+		}
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
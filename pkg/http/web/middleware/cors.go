@@ -0,0 +1,138 @@
+// Package middleware 常用的gin风格中间件
+// 通过 server.web.cors / server.web.security / server.web.compression 配置时由 igo.CreateWebServer 自动启用
+//
+//	srv := web.New(web.WithMiddleware(
+//		middleware.CORS(middleware.CORSConfig{AllowOrigins: []string{"https://*.example.com"}}),
+//		middleware.Compression(middleware.CompressionConfig{}),
+//	))
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	// 允许的来源 支持 * 和 https://*.example.com 形式的通配符
+	AllowOrigins []string
+	// 允许的方法 默认 GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS
+	AllowMethods []string
+	// 允许的请求头 为空时允许预检请求中声明的所有请求头
+	AllowHeaders []string
+	// 允许前端读取的响应头
+	ExposeHeaders []string
+	// 是否允许携带cookie 不能与AllowOrigins * 同时使用
+	AllowCredentials bool
+	// 预检结果的缓存时间
+	MaxAge time.Duration
+}
+
+// Validate 检查配置 允许任意来源时不能同时允许携带cookie
+func (cfg CORSConfig) Validate() error {
+	if cfg.AllowCredentials && slices.Contains(cfg.AllowOrigins, "*") {
+		return errors.New("cors: AllowOrigins * can not be used with AllowCredentials")
+	}
+	return nil
+}
+
+// CORS 跨域 预检请求直接返回204 配置错误时panic
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = []string{
+			http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+			http.MethodDelete, http.MethodHead, http.MethodOptions,
+		}
+	}
+	var (
+		allowAll      bool
+		exact         = make(map[string]bool)
+		wildcards     [][2]string
+		allowMethods  = strings.Join(cfg.AllowMethods, ", ")
+		allowHeaders  = strings.Join(cfg.AllowHeaders, ", ")
+		exposeHeaders = strings.Join(cfg.ExposeHeaders, ", ")
+		maxAge        = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	)
+	for _, o := range cfg.AllowOrigins {
+		switch {
+		case o == "*":
+			allowAll = true
+		case strings.Contains(o, "*"):
+			prefix, suffix, _ := strings.Cut(strings.ToLower(o), "*")
+			wildcards = append(wildcards, [2]string{prefix, suffix})
+		default:
+			exact[strings.ToLower(o)] = true
+		}
+	}
+	allowed := func(origin string) bool {
+		if allowAll {
+			return true
+		}
+		origin = strings.ToLower(origin)
+		if exact[origin] {
+			return true
+		}
+		for _, w := range wildcards {
+			if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+		if allowAll {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if exposeHeaders != "" {
+				h.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if req := c.GetHeader("Access-Control-Request-Headers"); req != "" {
+			h.Set("Access-Control-Allow-Headers", req)
+		}
+		if cfg.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
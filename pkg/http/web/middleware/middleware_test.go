@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	e := gin.New()
+	e.Use(CORS(CORSConfig{AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true}))
+	e.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	r := httptest.NewRequest(http.MethodOptions, "/", nil)
+	r.Header.Set("Origin", "https://a.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://a.example.com" {
+		t.Fatalf("preflight %d %v", w.Code, w.Header())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expect origin not allowed %v", w.Header())
+	}
+}
+
+func TestCompression(t *testing.T) {
+	body := strings.Repeat(`{"name":"igo"}`, 200)
+	e := gin.New()
+	e.Use(Compression(CompressionConfig{Algorithms: []string{"gzip"}}))
+	e.GET("/large", func(c *gin.Context) { c.Data(http.StatusOK, "application/json", []byte(body)) })
+	e.GET("/small", func(c *gin.Context) { c.Data(http.StatusOK, "application/json", []byte("{}")) })
+
+	r := httptest.NewRequest(http.MethodGet, "/large", nil)
+	r.Header.Set("Accept-Encoding", "gzip, br;q=0")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expect gzip %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != body {
+		t.Fatal("body mismatch")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/small", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "{}" {
+		t.Fatalf("expect uncompressed %v %s", w.Header(), w.Body)
+	}
+}
+
+func TestCompressionETag(t *testing.T) {
+	body := strings.Repeat(`{"name":"igo"}`, 200)
+	e := gin.New()
+	e.Use(Compression(CompressionConfig{Algorithms: []string{"gzip"}}))
+	e.GET("/", func(c *gin.Context) {
+		c.Header("ETag", `"v1"`)
+		if c.GetHeader("If-None-Match") == `"v1"` {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, "application/json", []byte(body))
+	})
+	do := func(encoding, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w
+	}
+
+	// 压缩前后的ETag不同
+	if w := do("", ""); w.Header().Get("ETag") != `"v1"` {
+		t.Fatalf("expect identity etag %v", w.Header())
+	}
+	w := do("gzip", "")
+	if w.Header().Get("ETag") != `"v1-gzip"` {
+		t.Fatalf("expect gzip etag %v", w.Header())
+	}
+	// 压缩后的ETag仍然可以用于条件请求
+	if w := do("gzip", `"v1-gzip"`); w.Code != http.StatusNotModified || w.Header().Get("ETag") != `"v1-gzip"` {
+		t.Fatalf("expect 304 %d %v", w.Code, w.Header())
+	}
+	if w := do("", `"v1-gzip"`); w.Code != http.StatusOK {
+		t.Fatalf("expect 200 for identity, got %d", w.Code)
+	}
+}
+
+func TestCORSConfig(t *testing.T) {
+	cfg := CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expect * with credentials rejected")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect panic")
+		}
+	}()
+	CORS(cfg)
+}
@@ -17,6 +17,7 @@ const (
 	formatInt64    = "int64"
 	formatFloat    = "float"
 	formatDateTime = "date-time"
+	formatBinary   = "binary"
 )
 
 // Schema represents an OpenAPI Schema Object
@@ -57,6 +58,9 @@ func parseDeep(v reflect.Value, name, tag string, out map[string]Schema) map[str
 		// RFC3339
 		case "time.Time":
 			out[name] = Schema{Type: schemaTypeString, Format: formatDateTime}
+		// 上传的文件
+		case "multipart.FileHeader":
+			out[name] = Schema{Type: schemaTypeString, Format: formatBinary}
 		default:
 			p := Schema{Type: schemaTypeObject, Properties: map[string]Schema{}, Required: make([]string, 0)}
 			for i := 0; i < v.NumField(); i++ {
@@ -124,6 +128,11 @@ func parseDeep(v reflect.Value, name, tag string, out map[string]Schema) map[str
 	return out
 }
 
+// BinarySchema 二进制内容 如文件下载
+func BinarySchema() map[string]Schema {
+	return map[string]Schema{"schema": {Type: schemaTypeString, Format: formatBinary}}
+}
+
 func Generate(input reflect.Value, tag string) map[string]Schema {
 	response := map[string]Schema{}
 	response = parseDeep(input, "schema", tag, response)
@@ -7,7 +7,8 @@ type Spec struct {
 	Tags       []Tag                     `json:"tags"`
 	Paths      map[string]map[string]any `json:"paths"`
 	Components struct {
-		Schema map[string]Schema `json:"schema"`
+		Schema          map[string]Schema         `json:"schema"`
+		SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
 	} `json:"components"`
 }
 
@@ -31,6 +32,13 @@ type Request struct {
 	Parameters  []Parameter     `json:"parameters,omitempty"`
 	RequestBody *Body           `json:"requestBody,omitempty"`
 	Responses   map[string]Body `json:"responses,omitempty"`
+	// 多个为或的关系 满足其一即可
+	Security []map[string][]string `json:"security,omitempty"`
+	// 需要的权限
+	Permissions []string `json:"x-permissions,omitempty"`
+	Deprecated  bool     `json:"deprecated,omitempty"`
+	// 请求体最大字节数
+	MaxBodySize int64 `json:"x-max-body-size,omitempty"`
 }
 
 type DocInfo struct {
@@ -60,3 +68,18 @@ type Body struct {
 	Content     map[string]map[string]Schema `json:"content,omitempty"`
 	Required    bool                         `json:"required,omitempty"`
 }
+
+// SecurityScheme 认证方式
+//
+// https://github.com/OAI/OpenAPI-Specification/blob/master/versions/3.0.3.md#security-scheme-object
+type SecurityScheme struct {
+	// apiKey http oauth2 openIdConnect
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	// apiKey only
+	Name string `json:"name,omitempty"`
+	In   string `json:"in,omitempty"`
+	// http only 如 bearer basic
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
@@ -1,18 +1,46 @@
 package web
 
 import (
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/parkingwang/igo/pkg/http/web/oas"
 )
 
 type option struct {
-	render          Renderer
-	dumpRequestBody bool
-	addr            string
-	routes          Routes
-	docInfo         *oas.DocInfo
-	bind            *validator.Validate
-	pprof           bool
+	render Renderer
+	dump   DumpOption
+	addr   string
+	routes Routes
+	// rpc路由 key为 method+空格+完整路径
+	routeIndex     map[string]*routeInfo
+	docInfo        *oas.DocInfo
+	bind           *validator.Validate
+	pprof          bool
+	sseHeartbeat   time.Duration
+	ws             WSOption
+	wsconns        *wsConnSet
+	docEnvelope    func(oas.Schema) oas.Schema
+	codecs         *codecSet
+	authenticators map[string]Authenticator
+	authorizer     Authorizer
+	rateLimiters   map[string]RateLimiter
+	timeout        TimeoutOption
+	bodyLimit      BodyLimitOption
+	middlewares    []gin.HandlerFunc
+	tls            *TLSOption
+	h2c            bool
+	listens        []string
+	panicHandlers  []PanicHandler
+	errorHandlers  []func(*gin.Context, error)
+	providers      map[reflect.Type]provider
+	versioning     VersionOption
+	versions       []apiVersion
+	proxies        []*reverseProxy
+	// 根路径的静态文件 在NoRoute中处理
+	statics []*staticFS
 }
 
 func defaultOption() *option {
@@ -24,6 +52,18 @@ func defaultOption() *option {
 		routes: make([]*routeInfo, 0),
 		bind:   v,
 		pprof:  true,
+		// 大多数代理默认60s无数据会断开连接
+		sseHeartbeat: time.Second * 15,
+		ws:           defaultWSOption(),
+		timeout:      defaultTimeoutOption(),
+		bodyLimit:    defaultBodyLimitOption(),
+		wsconns:      &wsConnSet{},
+		// 默认只输出json 其他格式需要通过WithCodec启用
+		codecs:         newCodecSet(CodecJSON),
+		authenticators: make(map[string]Authenticator),
+		routeIndex:     make(map[string]*routeInfo),
+		rateLimiters:   make(map[string]RateLimiter),
+		providers:      make(map[reflect.Type]provider),
 	}
 }
 
@@ -36,10 +76,41 @@ func WithResponseRender(r Renderer) Option {
 	}
 }
 
-// WithDumpRequestBody 是否输出请求体
+// WithEnvelope 使用统一的响应结构输出 文档中的响应结构同步包装
+func WithEnvelope(e Envelope) Option {
+	return func(opt *option) {
+		opt.render = e.Render
+		opt.docEnvelope = e.Schema
+	}
+}
+
+// WithResponseDoc 自定义Renderer包装了响应结构时 通过此方法让文档保持一致
+func WithResponseDoc(f func(data oas.Schema) oas.Schema) Option {
+	return func(opt *option) {
+		opt.docEnvelope = f
+	}
+}
+
+// WithCodec 注册编解码 媒体类型相同的编解码会被替换
+// 如 WithCodec(web.CodecXML, web.CodecMsgPack)
+func WithCodec(cs ...Codec) Option {
+	return func(opt *option) {
+		opt.codecs.add(cs...)
+	}
+}
+
+// WithMiddleware 添加全局的gin中间件 在tracing和recovery之后 所有路由之前执行
+// 对未匹配的路由同样生效 如CORS预检请求
+func WithMiddleware(h ...gin.HandlerFunc) Option {
+	return func(o *option) {
+		o.middlewares = append(o.middlewares, h...)
+	}
+}
+
+// WithDumpRequestBody 是否输出请求体 更多设置使用WithDump
 func WithDumpRequestBody(o bool) Option {
 	return func(opt *option) {
-		opt.dumpRequestBody = o
+		opt.dump.Request = o
 	}
 }
 
@@ -60,3 +131,31 @@ func WithPProf(o bool) Option {
 		opt.pprof = o
 	}
 }
+
+// WithSSEHeartbeat SSE推送的心跳间隔 小于等于0则不发送心跳
+func WithSSEHeartbeat(d time.Duration) Option {
+	return func(o *option) {
+		o.sseHeartbeat = d
+	}
+}
+
+// WithWebSocket websocket配置 未设置的字段使用默认值
+func WithWebSocket(ws WSOption) Option {
+	return func(o *option) {
+		if ws.PingInterval > 0 {
+			o.ws.PingInterval = ws.PingInterval
+		}
+		if ws.PongWait > 0 {
+			o.ws.PongWait = ws.PongWait
+		}
+		if ws.WriteWait > 0 {
+			o.ws.WriteWait = ws.WriteWait
+		}
+		if ws.ReadLimit > 0 {
+			o.ws.ReadLimit = ws.ReadLimit
+		}
+		if ws.CheckOrigin != nil {
+			o.ws.CheckOrigin = ws.CheckOrigin
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/client"
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/requestid"
+	"github.com/sony/gobreaker/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ProxyOption 反向代理设置
+type ProxyOption struct {
+	// 额外的后端地址 与target一起轮询
+	Targets []string
+	// 转发前去掉路由的前缀 如 /legacy/user -> /user
+	StripPrefix bool
+	// 修改转发的路径 在StripPrefix之后执行
+	Rewrite func(path string) string
+	// 转发时设置的请求头 值为空时删除
+	RequestHeaders map[string]string
+	// 返回时设置的响应头 值为空时删除
+	ResponseHeaders map[string]string
+	// 健康检查 不健康的后端不再转发 Path为空不检查
+	HealthCheck ProxyHealthCheck
+	// 熔断器设置 每个后端独立 网络错误和5xx按失败统计 nil不启用
+	Breaker *gobreaker.Settings
+	// 默认使用 http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// ProxyHealthCheck 主动健康检查 响应2xx/3xx为健康
+type ProxyHealthCheck struct {
+	Path string
+	// 检查间隔 默认10s
+	Interval time.Duration
+	// 单次检查超时 默认2s
+	Timeout time.Duration
+}
+
+type proxyTarget struct {
+	url     *url.URL
+	proxy   *httputil.ReverseProxy
+	breaker *client.BreakerTransport
+	healthy atomic.Bool
+}
+
+// available 健康且未熔断
+func (t *proxyTarget) available() bool {
+	return t.healthy.Load() && (t.breaker == nil || t.breaker.State() != gobreaker.StateOpen)
+}
+
+type reverseProxy struct {
+	opt     ProxyOption
+	prefix  string
+	targets []*proxyTarget
+	next    atomic.Uint64
+}
+
+type proxyErrorKey struct{}
+
+func newReverseProxy(prefix string, targets []string, opt ProxyOption) *reverseProxy {
+	p := &reverseProxy{opt: opt, prefix: prefix}
+	transport := opt.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			panic(fmt.Sprintf("proxy %s: invalid target %q", prefix, target))
+		}
+		t := &proxyTarget{url: u}
+		t.healthy.Store(true)
+		rt := transport
+		if opt.Breaker != nil {
+			s := *opt.Breaker
+			if s.Name == "" {
+				s.Name = u.Host
+			}
+			t.breaker = client.NewBreakerTransport(s, transport)
+			rt = t.breaker
+		}
+		t.proxy = &httputil.ReverseProxy{
+			Rewrite:        p.rewrite(u),
+			Transport:      rt,
+			ModifyResponse: p.modifyResponse,
+			ErrorHandler:   proxyErrorHandler,
+			// 支持SSE等流式响应
+			FlushInterval: -1,
+		}
+		p.targets = append(p.targets, t)
+	}
+	return p
+}
+
+func (p *reverseProxy) rewrite(target *url.URL) func(*httputil.ProxyRequest) {
+	return func(pr *httputil.ProxyRequest) {
+		path := pr.In.URL.Path
+		if p.opt.StripPrefix {
+			path = strings.TrimPrefix(path, p.prefix)
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+		}
+		if p.opt.Rewrite != nil {
+			path = p.opt.Rewrite(path)
+		}
+		pr.Out.URL.Path, pr.Out.URL.RawPath = path, ""
+		pr.SetURL(target)
+		pr.SetXForwarded()
+		for k, v := range p.opt.RequestHeaders {
+			if v == "" {
+				pr.Out.Header.Del(k)
+			} else {
+				pr.Out.Header.Set(k, v)
+			}
+		}
+		ctx := pr.Out.Context()
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(pr.Out.Header))
+		if id := requestid.FromContext(ctx); id != "" {
+			pr.Out.Header.Set(requestid.Header, id)
+		}
+	}
+}
+
+func (p *reverseProxy) modifyResponse(res *http.Response) error {
+	for k, v := range p.opt.ResponseHeaders {
+		if v == "" {
+			res.Header.Del(k)
+		} else {
+			res.Header.Set(k, v)
+		}
+	}
+	return nil
+}
+
+// proxyErrorHandler 记录错误 由serveProxy统一输出
+func proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if perr, ok := r.Context().Value(proxyErrorKey{}).(*error); ok {
+		*perr = err
+	}
+}
+
+// proxyWriter 隐藏gin.ResponseWriter的CloseNotify 底层不支持时会panic
+// Flush和Hijack通过Unwrap获取
+type proxyWriter struct {
+	http.ResponseWriter
+}
+
+func (w proxyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// pick 轮询可用的后端
+func (p *reverseProxy) pick() *proxyTarget {
+	n := uint64(len(p.targets))
+	start := p.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if t := p.targets[(start+i)%n]; t.available() {
+			return t
+		}
+	}
+	return nil
+}
+
+// healthCheck 定时检查所有后端 直到ctx取消
+func (p *reverseProxy) healthCheck(ctx context.Context) {
+	hc := p.opt.HealthCheck
+	if hc.Path == "" {
+		return
+	}
+	if hc.Interval <= 0 {
+		hc.Interval = time.Second * 10
+	}
+	if hc.Timeout <= 0 {
+		hc.Timeout = time.Second * 2
+	}
+	hclient := &http.Client{Transport: p.opt.Transport, Timeout: hc.Timeout}
+	ticker := time.NewTicker(hc.Interval)
+	defer ticker.Stop()
+	for {
+		for _, t := range p.targets {
+			healthy := checkTarget(ctx, hclient, t.url.JoinPath(hc.Path).String())
+			if t.healthy.Swap(healthy) != healthy {
+				slog.WarnContext(ctx, "proxy target health changed",
+					slog.String("prefix", p.prefix),
+					slog.String("target", t.url.String()),
+					slog.Bool("healthy", healthy),
+				)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkTarget(ctx context.Context, c *http.Client, u string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return false
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	return res.StatusCode < http.StatusBadRequest
+}
+
+// serveProxy 转发请求 访问日志中记录后端地址
+func serveProxy(opt *option, info *routeInfo, p *reverseProxy) gin.HandlerFunc {
+	tracer := otel.GetTracerProvider().Tracer("github.com/parkingwang/igo/pkg/http/web")
+	return func(c *gin.Context) {
+		deprecationHeaders(c, info)
+		if err := guard(c, opt, info); err != nil {
+			warpRender(opt, c, nil, err)
+			return
+		}
+		t := p.pick()
+		if t == nil {
+			warpRender(opt, c, nil, code.NewCodeError(http.StatusServiceUnavailable, "no available upstream"))
+			return
+		}
+		AddAccessLogAttrs(c, slog.String("proxy.target", t.url.Host))
+		clearWriteDeadline(c.Writer)
+
+		ctx, span := tracer.Start(c.Request.Context(), "proxy "+c.Request.Method+" "+t.url.Host,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("proxy.target", t.url.String())),
+		)
+		defer span.End()
+		var perr error
+		ctx = context.WithValue(ctx, proxyErrorKey{}, &perr)
+		t.proxy.ServeHTTP(proxyWriter{c.Writer}, c.Request.WithContext(ctx))
+
+		if perr != nil {
+			span.RecordError(perr)
+			// 原始错误只记录到日志 不暴露后端地址
+			c.Error(perr)
+			var err error
+			switch {
+			case errors.Is(perr, context.Canceled):
+				// 客户端已断开
+				c.Abort()
+				return
+			case errors.Is(perr, gobreaker.ErrOpenState), errors.Is(perr, gobreaker.ErrTooManyRequests):
+				err = code.NewCodeError(http.StatusServiceUnavailable, "upstream circuit breaker open")
+			default:
+				err = code.NewCodeError(http.StatusBadGateway, "bad gateway")
+			}
+			if !c.Writer.Written() {
+				warpRender(opt, c, nil, err)
+			}
+		}
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindClient))
+	}
+}
+
+func (s *route) Proxy(prefix, target string, opts ...ProxyOption) Commenter {
+	var o ProxyOption
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	targets := append([]string{target}, o.Targets...)
+	p := newReverseProxy(s.basepath+prefix, targets, o)
+	s.opt.proxies = append(s.opt.proxies, p)
+
+	// 仅用于路由表和认证等设置 不生成文档
+	info := s.opt.routes.addRoute(s.basepath, prefix+"/*path", serveProxy, "ANY")
+	if info != nil {
+		info.proxy = true
+		info.version = s.version
+		info.pcName = "proxy " + strings.Join(targets, ",")
+		info.comment = ""
+	}
+	serve := serveProxy(s.opt, info, p)
+	if prefix != "" {
+		s.r.Any(prefix, serve)
+	}
+	s.r.Any(prefix+"/*path", serve)
+	return &route{info: info}
+}
@@ -0,0 +1,47 @@
+package web_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/webtest"
+	"github.com/sony/gobreaker/v2"
+)
+
+func TestProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Internal", "1")
+		fmt.Fprintf(w, "%s %s %s %t", r.URL.Path, r.Header.Get("X-Gateway"), r.Header.Get("X-Request-ID"), r.Header.Get("Traceparent") != "")
+	}))
+	defer backend.Close()
+
+	s := webtest.New(t, func(r web.Router) {
+		r.Proxy("/legacy", backend.URL, web.ProxyOption{
+			StripPrefix:     true,
+			RequestHeaders:  map[string]string{"X-Gateway": "igo"},
+			ResponseHeaders: map[string]string{"X-Internal": ""},
+			Breaker: &gobreaker.Settings{
+				ReadyToTrip: func(c gobreaker.Counts) bool { return c.ConsecutiveFailures >= 2 },
+			},
+		})
+		// 不可用的后端
+		r.Proxy("/down", "http://127.0.0.1:1")
+	})
+	s.Get("/legacy/user/1").WithHeader("X-Request-ID", "abc").
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("X-Internal", "").
+		ExpectBodyContains("/user/1 igo abc true")
+	s.Get("/down/x").ExpectStatus(http.StatusBadGateway)
+
+	s.Get("/legacy/fail").ExpectStatus(http.StatusInternalServerError)
+	s.Get("/legacy/fail").ExpectStatus(http.StatusInternalServerError)
+	// 熔断后没有可用的后端
+	s.Get("/legacy/user/1").ExpectStatus(http.StatusServiceUnavailable)
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/code"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RateLimiter 限流
+// 内置的本地令牌桶和redis滑动窗口实现见 pkg/http/web/ratelimit
+type RateLimiter interface {
+	// Allow 返回nil表示放行 可以在此设置 X-RateLimit-* 响应头
+	// 返回 *code.CodeError 时原样输出 其他错误按429输出
+	Allow(c *gin.Context) error
+}
+
+// WithRateLimiter 注册限流策略 路由通过RateLimit(name)声明使用
+func WithRateLimiter(name string, l RateLimiter) Option {
+	return func(o *option) {
+		o.rateLimiters[name] = l
+	}
+}
+
+var rateLimitRejected, _ = meter.Int64Counter(
+	"http.server.ratelimit.rejected",
+	metric.WithDescription("Number of requests rejected by rate limiter"),
+)
+
+// rateLimit 依次执行路由声明的限流策略 在认证之后执行 以便按身份限流
+func rateLimit(c *gin.Context, opt *option, policies []string) error {
+	for _, name := range policies {
+		l, ok := opt.rateLimiters[name]
+		if !ok {
+			return fmt.Errorf("rate limiter %s not register", name)
+		}
+		err := l.Allow(c)
+		if err == nil {
+			continue
+		}
+		rateLimitRejected.Add(c, 1, metric.WithAttributes(
+			attribute.String("ratelimit.policy", name),
+			attribute.String("http.route", c.FullPath()),
+		))
+		var ce *code.CodeError
+		if errors.As(err, &ce) {
+			return err
+		}
+		return code.NewTooManyRequestsError(err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// localStore 本地内存令牌桶 仅在单个进程内生效
+type localStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	// 上次清理空闲桶的时间
+	sweptAt time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Local 本地内存令牌桶 以Limit/Period的速率补充令牌 容量为Burst
+func Local() Store {
+	return &localStore{
+		buckets: make(map[string]*bucket),
+		sweptAt: time.Now(),
+		now:     time.Now,
+	}
+}
+
+func (s *localStore) Take(_ context.Context, key string, rule Rule) (Result, error) {
+	now := s.now()
+	// 每秒补充的令牌
+	rate := float64(rule.Limit) / rule.Period.Seconds()
+	capacity := float64(rule.Burst)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now, capacity/rate)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	res := Result{Limit: rule.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.ResetAfter = seconds((capacity - b.tokens) / rate)
+	return res, nil
+}
+
+// sweep 定期清理已经补满的桶 避免key过多时内存持续增长
+func (s *localStore) sweep(now time.Time, fill float64) {
+	idle := seconds(fill)
+	if idle < time.Minute {
+		idle = time.Minute
+	}
+	if now.Sub(s.sweptAt) < idle {
+		return
+	}
+	s.sweptAt = now
+	for k, b := range s.buckets {
+		if now.Sub(b.last) > idle {
+			delete(s.buckets, k)
+		}
+	}
+}
+
+func seconds(v float64) time.Duration {
+	return time.Duration(v * float64(time.Second))
+}
@@ -0,0 +1,179 @@
+// Package ratelimit 内置的限流 实现了 web.RateLimiter
+// 本地内存使用令牌桶 多副本共享时使用redis滑动窗口
+//
+//	srv := web.New(
+//		web.WithRateLimiter("api", ratelimit.New(ratelimit.Local(), ratelimit.Rule{Limit: 100, Period: time.Minute}, ratelimit.ByIP)),
+//	)
+//	api := srv.Router().Group("/api")
+//	api.RateLimit("api")
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/auth"
+	"github.com/parkingwang/igo/pkg/store/redis"
+)
+
+const (
+	HeaderLimit      = "X-RateLimit-Limit"
+	HeaderRemaining  = "X-RateLimit-Remaining"
+	HeaderReset      = "X-RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// Rule 限流规则 Period内最多Limit次请求
+type Rule struct {
+	Limit  int
+	Period time.Duration
+	// 令牌桶容量 允许的突发请求数 默认等于Limit 仅本地令牌桶有效
+	Burst int
+}
+
+// Result 一次限流判断的结果
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// 被拒绝时需要等待的时间
+	RetryAfter time.Duration
+	// 额度完全恢复需要的时间
+	ResetAfter time.Duration
+}
+
+// Store 限流状态的存储
+type Store interface {
+	Take(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// KeyFunc 计算限流的维度 返回空字符串时不限流
+type KeyFunc func(c *gin.Context) string
+
+// ByIP 按客户端ip限流
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByPrincipal 按认证后的身份限流 未认证时按ip限流
+func ByPrincipal(c *gin.Context) string {
+	if p, ok := auth.FromContext(c); ok {
+		return "sub:" + p.Scheme + ":" + p.Subject
+	}
+	return ByIP(c)
+}
+
+// ByRoute 按路由限流 所有客户端共享额度
+func ByRoute(c *gin.Context) string {
+	return "route:" + c.Request.Method + " " + c.FullPath()
+}
+
+// Compose 组合多个维度 如 Compose(ByRoute, ByIP) 表示每个ip在每个路由上的额度
+func Compose(fs ...KeyFunc) KeyFunc {
+	return func(c *gin.Context) string {
+		keys := make([]string, 0, len(fs))
+		for _, f := range fs {
+			k := f(c)
+			if k == "" {
+				return ""
+			}
+			keys = append(keys, k)
+		}
+		return strings.Join(keys, "|")
+	}
+}
+
+// Limiter 实现了 web.RateLimiter
+type Limiter struct {
+	store Store
+	rule  Rule
+	key   KeyFunc
+}
+
+// New 创建限流 Limit和Period必须大于0
+func New(store Store, rule Rule, key KeyFunc) *Limiter {
+	if rule.Limit <= 0 || rule.Period <= 0 {
+		panic(fmt.Sprintf("ratelimit: limit and period must be positive, got limit %d period %s", rule.Limit, rule.Period))
+	}
+	if rule.Burst <= 0 {
+		rule.Burst = rule.Limit
+	}
+	if key == nil {
+		key = ByIP
+	}
+	return &Limiter{store: store, rule: rule, key: key}
+}
+
+func (l *Limiter) Allow(c *gin.Context) error {
+	key := l.key(c)
+	if key == "" {
+		return nil
+	}
+	res, err := l.store.Take(c, key, l.rule)
+	if err != nil {
+		// 存储不可用时放行 避免限流导致服务不可用
+		slog.WarnContext(c, "ratelimit: take failed", slog.String("key", key), slog.String("err", err.Error()))
+		return nil
+	}
+	h := c.Writer.Header()
+	h.Set(HeaderLimit, strconv.Itoa(res.Limit))
+	h.Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+	h.Set(HeaderReset, strconv.Itoa(ceilSeconds(res.ResetAfter)))
+	if res.Allowed {
+		return nil
+	}
+	h.Set(HeaderRetryAfter, strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	return fmt.Errorf("rate limit exceeded, retry after %ds", ceilSeconds(res.RetryAfter))
+}
+
+// Config 限流配置
+type Config struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+	// 限流维度 ip/principal/route 多个使用逗号分隔 如 "route,ip" 默认ip
+	Key string
+	// 使用pkg/store/redis中注册的名称 为空使用本地内存
+	Redis string
+}
+
+// FromConfig 通过配置创建限流
+func FromConfig(cfg Config) (*Limiter, error) {
+	if cfg.Limit <= 0 || cfg.Period <= 0 {
+		return nil, fmt.Errorf("ratelimit: limit and period are required")
+	}
+	var keys []KeyFunc
+	for _, k := range strings.Split(cfg.Key, ",") {
+		switch strings.TrimSpace(k) {
+		case "", "ip":
+			keys = append(keys, ByIP)
+		case "principal":
+			keys = append(keys, ByPrincipal)
+		case "route":
+			keys = append(keys, ByRoute)
+		default:
+			return nil, fmt.Errorf("ratelimit: unsupported key %s", k)
+		}
+	}
+	var store Store
+	if cfg.Redis != "" {
+		store = NewRedis(redis.Get(cfg.Redis), "")
+	} else {
+		store = Local()
+	}
+	return New(store, Rule{Limit: cfg.Limit, Period: cfg.Period, Burst: cfg.Burst}, Compose(keys...)), nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+var _ web.RateLimiter = (*Limiter)(nil)
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+func TestLocal(t *testing.T) {
+	s := Local().(*localStore)
+	now := time.Unix(0, 0)
+	s.now = func() time.Time { return now }
+	rule := Rule{Limit: 2, Period: time.Second, Burst: 2}
+	for i := 0; i < 2; i++ {
+		if res, _ := s.Take(context.Background(), "k", rule); !res.Allowed {
+			t.Fatalf("take %d rejected", i)
+		}
+	}
+	res, _ := s.Take(context.Background(), "k", rule)
+	if res.Allowed || res.RetryAfter != time.Millisecond*500 {
+		t.Fatalf("expect rejected %+v", res)
+	}
+	now = now.Add(time.Millisecond * 500)
+	if res, _ := s.Take(context.Background(), "k", rule); !res.Allowed {
+		t.Fatalf("expect refilled %+v", res)
+	}
+}
+
+func TestRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := NewRedis(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), "")
+	rule := Rule{Limit: 2, Period: time.Minute}
+	for i := 0; i < 2; i++ {
+		if res, err := s.Take(context.Background(), "k", rule); err != nil || !res.Allowed {
+			t.Fatalf("take %d %+v %v", i, res, err)
+		}
+	}
+	res, err := s.Take(context.Background(), "k", rule)
+	if err != nil || res.Allowed || res.Remaining != 0 || res.RetryAfter <= 0 {
+		t.Fatalf("expect rejected %+v %v", res, err)
+	}
+}
+
+func TestNewInvalidRule(t *testing.T) {
+	for _, rule := range []Rule{{Period: time.Second}, {Limit: 10}, {Limit: -1, Period: time.Second}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expect panic for %+v", rule)
+				}
+			}()
+			New(Local(), rule, nil)
+		}()
+	}
+}
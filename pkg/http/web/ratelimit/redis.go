@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// 滑动窗口 使用有序集合记录窗口内每次请求的时间
+// 返回 {是否放行, 窗口内请求数, 最早一次请求过期的剩余毫秒}
+var slidingWindow = goredis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+local reset = 0
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+type redisStore struct {
+	client goredis.Scripter
+	prefix string
+}
+
+// NewRedis redis滑动窗口 多个副本共享额度 Burst无效
+// 窗口时间使用各副本的本地时间 需要保证时钟同步
+func NewRedis(client goredis.Scripter, prefix string) Store {
+	if prefix == "" {
+		prefix = "igo:ratelimit:"
+	}
+	return &redisStore{client: client, prefix: prefix}
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, rule Rule) (Result, error) {
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Uint64())
+	v, err := slidingWindow.Run(ctx, s.client, []string{s.prefix + key},
+		now, rule.Period.Milliseconds(), rule.Limit, member,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{
+		Allowed:    v[0] == 1,
+		Limit:      rule.Limit,
+		Remaining:  rule.Limit - int(v[1]),
+		ResetAfter: time.Duration(v[2]) * time.Millisecond,
+	}
+	if !res.Allowed {
+		res.RetryAfter = res.ResetAfter
+	}
+	return res, nil
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/internal/panics"
+	"github.com/parkingwang/igo/pkg/http/code"
+)
+
+// PanicError recover捕获的panic 包含panic时的堆栈
+type PanicError = panics.Error
+
+// PanicHandler panic恢复并输出500之后调用 用于上报到错误跟踪系统
+type PanicHandler func(c *gin.Context, err *PanicError)
+
+// WithPanicHandler panic时的回调
+func WithPanicHandler(h PanicHandler) Option {
+	return func(o *option) {
+		o.panicHandlers = append(o.panicHandlers, h)
+	}
+}
+
+// WithErrorHandler rpc方法返回5xx错误时的回调 用于上报到错误跟踪系统
+// 4xx属于客户端错误 不会调用
+func WithErrorHandler(h func(c *gin.Context, err error)) Option {
+	return func(o *option) {
+		o.errorHandlers = append(o.errorHandlers, h)
+	}
+}
+
+// recovery 捕获panic 记录堆栈到日志和span 输出500
+func recovery(opt *option) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// 同net/http 用于中断响应
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			e := panics.New(v)
+			// 客户端断开连接 无法再输出
+			if err := e.Unwrap(); errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+				slog.LogAttrs(c, slog.LevelWarn, "gin.panic", slog.String("err", err.Error()))
+				c.Abort()
+				return
+			}
+			panics.Record(c, "gin.panic", e, slog.String("path", c.Request.URL.Path))
+			c.Abort()
+			if !c.Writer.Written() {
+				opt.render(c, nil,
+					code.NewCodeError(
+						http.StatusInternalServerError,
+						"%s", http.StatusText(http.StatusInternalServerError),
+					),
+				)
+			}
+			for _, h := range opt.panicHandlers {
+				h(c, e)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// serverError 5xx错误时调用WithErrorHandler注册的回调
+func serverError(opt *option, c *gin.Context, err error) {
+	if len(opt.errorHandlers) == 0 {
+		return
+	}
+	status := http.StatusInternalServerError
+	var ce *code.CodeError
+	if errors.As(err, &ce) {
+		status = ce.Code
+	}
+	if status < http.StatusInternalServerError {
+		return
+	}
+	for _, h := range opt.errorHandlers {
+		h(c, err)
+	}
+}
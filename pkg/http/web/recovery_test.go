@@ -0,0 +1,73 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/http/web"
+)
+
+func TestRecovery(t *testing.T) {
+	var (
+		panics []*web.PanicError
+		errs   []error
+	)
+	h := newTestServer(t, func(r web.Router) {
+		r.Get("/panic", func(ctx context.Context, in *web.Empty) (string, error) {
+			panic("boom")
+		})
+		r.Get("/abort", func(c *gin.Context) {
+			panic(http.ErrAbortHandler)
+		})
+		r.Get("/error", func(ctx context.Context, in *web.Empty) (string, error) {
+			return "", code.NewCodeError(http.StatusBadGateway, "upstream failed")
+		})
+		r.Get("/notfound", func(ctx context.Context, in *web.Empty) (string, error) {
+			return "", code.NewNotfoundError("not found")
+		})
+	},
+		web.WithPanicHandler(func(c *gin.Context, err *web.PanicError) {
+			panics = append(panics, err)
+		}),
+		web.WithErrorHandler(func(c *gin.Context, err error) {
+			errs = append(errs, err)
+		}),
+	)
+
+	// 不暴露panic的内容
+	w := do(h, http.MethodGet, "/panic", nil)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "traceid") {
+		t.Fatalf("unexpected %d %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "boom") {
+		t.Fatalf("panic value leaked: %s", w.Body)
+	}
+	if len(panics) != 1 || panics[0].Value != "boom" || !strings.Contains(string(panics[0].Stack), "recovery_test.go") {
+		t.Fatalf("expect panic handler called with stack %v", panics)
+	}
+
+	// http.ErrAbortHandler交给net/http处理
+	func() {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("expect ErrAbortHandler re-panicked, got %v", v)
+			}
+		}()
+		do(h, http.MethodGet, "/abort", nil)
+	}()
+
+	// 只有5xx调用ErrorHandler
+	if w := do(h, http.MethodGet, "/error", nil); w.Code != http.StatusBadGateway {
+		t.Fatalf("expect 502, got %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/notfound", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404, got %d", w.Code)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "upstream failed") {
+		t.Fatalf("expect only 5xx reported %v", errs)
+	}
+}
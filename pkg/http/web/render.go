@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/requestid"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -26,24 +27,32 @@ func DefaultRender(ctx *gin.Context, data any, err error) {
 		span := trace.SpanFromContext(ctx)
 		span.SetStatus(codes.Error, err.Error())
 		resp := DefaultErrorResponse{
-			Message: e.Message,
-			TraceID: span.SpanContext().TraceID().String(),
+			Message:   e.Message,
+			TraceID:   span.SpanContext().TraceID().String(),
+			RequestID: requestid.FromContext(ctx),
 		}
-		ctx.JSON(e.Code, resp)
+		Negotiate(ctx, e.Code, resp)
 	} else {
 		if data != nil {
-			ctx.JSON(http.StatusOK, data)
+			Negotiate(ctx, http.StatusOK, data)
 			return
 		}
 	}
 }
 
 type DefaultErrorResponse struct {
-	Message string `json:"message"`
-	TraceID string `json:"traceid"`
+	Message string `json:"message" xml:"message" yaml:"message"`
+	TraceID string `json:"traceid" xml:"traceid" yaml:"traceid"`
+	// trace未采样或未启用时traceid为全0 使用requestid定位请求
+	RequestID string `json:"requestid" xml:"requestid" yaml:"requestid"`
 }
 
 func warpRender(opt *option, ctx *gin.Context, data any, err error) {
+	// 中间件或rpc方法读取请求体超出限制
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		err = bodyTooLargeError(mbe.Limit)
+	}
 	if err != nil {
 		var rawErr *code.CodeError
 		if errors.As(err, &rawErr) {
@@ -51,10 +60,19 @@ func warpRender(opt *option, ctx *gin.Context, data any, err error) {
 		} else {
 			ctx.Set("gin.response.err", err.Error())
 		}
+		serverError(opt, ctx, err)
 	}
-	// 输出 response ？
-	// 无法确定结果集大小 贸然输出可能会导致造成大量的垃圾日志
-	// slog.Ctx(ctx).Info("gin.response.data", slog.Any("data", data))
 	// 渲染结构
 	opt.render(ctx, data, err)
 }
+
+// Abort 中断请求并通过Renderer输出错误
+// 用于gin风格的中间件 保证错误输出格式一致
+func Abort(ctx *gin.Context, err error) {
+	ctx.Abort()
+	if opt, ok := optionFrom(ctx); ok {
+		warpRender(opt, ctx, nil, err)
+		return
+	}
+	DefaultRender(ctx, nil, err)
+}
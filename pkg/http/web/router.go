@@ -2,12 +2,16 @@ package web
 
 import (
 	"fmt"
+	"io/fs"
 	"net/http"
 	"os"
+	"path"
 	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,13 +25,61 @@ type Router interface {
 	Patch(path string, handler ...any) Commenter
 	Delete(path string, handler ...any) Commenter
 	Handle(method, path string, handler ...any) Commenter
+	// WebSocket 注册websocket路由 middleware为gin风格的中间件
+	WebSocket(path string, handler WSHandler, middleware ...gin.HandlerFunc) Commenter
 	// 同gin
 	Use(handler ...gin.HandlerFunc) Router
 	Group(path string, handler ...gin.HandlerFunc) GroupCommenter
+	// Controller 注册控制器 创建分组并注册控制器的路由 控制器可以实现以下方法
+	//
+	//	Prefix() string                分组路径 默认为类型名去掉Controller 如 UserController -> /user
+	//	Middleware() []gin.HandlerFunc 分组的中间件
+	//	Routes(r Router)               自行注册路由 未实现时按方法名注册
+	//
+	// 按方法名注册 List/Create -> GET/POST / Get/Update/Patch/Delete -> GET/PUT/PATCH/DELETE /:id
+	// Get/Post/Put/Patch/Delete开头的方法 如 PostLogin -> POST /login
+	Controller(c any) GroupCommenter
+	// Version 注册版本分组 路径为 /v 如 Version("v2") -> /v2
+	// 通过 WithVersioning 也可以使用请求头或Accept指定版本 文档按版本生成在 /debug/doc/v2
+	Version(v string) GroupCommenter
+	// Proxy 将prefix下的所有请求转发到target 如 Proxy("/legacy", "http://10.0.0.1:8080")
+	// 支持多个后端轮询/健康检查/熔断 可以声明Auth/RateLimit等 Timeout和Dump不生效
+	Proxy(prefix, target string, opt ...ProxyOption) Commenter
+	// Static 静态文件 支持embed.FS 文件不存在时输出纯文本的404 不生成文档
+	//
+	//	//go:embed dist
+	//	var dist embed.FS
+	//	sub, _ := fs.Sub(dist, "dist")
+	//	r.Static("/admin", sub, web.StaticOption{SPA: true})
+	Static(prefix string, fsys fs.FS, opt ...StaticOption) Commenter
 }
 
 type Commenter interface {
-	Comment(string)
+	Comment(string) Commenter
+	// Auth 声明需要的认证方式 多个时满足其一即可
+	// 分组上声明时对所有子路由生效 子路由调用Auth()不带参数表示无需认证
+	Auth(schemes ...string) Commenter
+	// Require 声明需要的权限 需要同时满足 分组上声明的权限会叠加到子路由
+	// 通过 WithAuthorizer 注册的Authorizer验证
+	Require(perms ...string) Commenter
+	// RateLimit 声明使用的限流策略 分组上声明的策略会叠加到子路由
+	// 通过 WithRateLimiter 注册
+	RateLimit(policies ...string) Commenter
+	// Timeout rpc方法的超时时间 超时后ctx被取消并输出504
+	// 分组上声明时对所有子路由生效 子路由可以覆盖
+	Timeout(d time.Duration) Commenter
+	// Dump 请求/响应体输出到日志 覆盖WithDump的设置
+	// 分组上声明时对所有子路由生效 子路由可以覆盖
+	Dump(d DumpOption) Commenter
+	// Deprecated 声明已废弃 响应中添加Deprecation头 可以指定废弃的时间
+	// 分组上声明时对所有子路由生效
+	Deprecated(at ...time.Time) Commenter
+	// Sunset 下线时间 响应中添加Sunset头
+	// 分组上声明时对所有子路由生效 子路由可以覆盖
+	Sunset(t time.Time) Commenter
+	// BodyLimit 请求体最大字节数 覆盖WithBodyLimit的设置 小于0不限制
+	// 分组上声明时对所有子路由生效 子路由可以覆盖
+	BodyLimit(n int64) Commenter
 }
 
 type GroupCommenter interface {
@@ -41,6 +93,8 @@ type route struct {
 	r        gin.IRoutes
 	isDir    bool
 	info     *routeInfo
+	// 所属的版本
+	version string
 }
 
 func (s *route) Get(path string, handler ...any) Commenter {
@@ -63,10 +117,71 @@ func (s *route) Delete(path string, handler ...any) Commenter {
 	return s.Handle(http.MethodDelete, path, handler...)
 }
 
-func (s *route) Comment(c string) {
+func (s *route) Comment(c string) Commenter {
 	if s.info != nil {
 		s.info.comment = c
 	}
+	return s
+}
+
+func (s *route) Auth(schemes ...string) Commenter {
+	if s.info != nil {
+		s.info.auth = append(make([]string, 0, len(schemes)), schemes...)
+	}
+	return s
+}
+
+func (s *route) Require(perms ...string) Commenter {
+	if s.info != nil {
+		s.info.requires = append(s.info.requires, perms...)
+	}
+	return s
+}
+
+func (s *route) RateLimit(policies ...string) Commenter {
+	if s.info != nil {
+		s.info.limits = append(s.info.limits, policies...)
+	}
+	return s
+}
+
+func (s *route) Timeout(d time.Duration) Commenter {
+	if s.info != nil {
+		s.info.timeout = d
+	}
+	return s
+}
+
+func (s *route) Dump(d DumpOption) Commenter {
+	if s.info != nil {
+		s.info.dump = &d
+	}
+	return s
+}
+
+func (s *route) Deprecated(at ...time.Time) Commenter {
+	if s.info != nil {
+		var t time.Time
+		if len(at) > 0 {
+			t = at[0]
+		}
+		s.info.deprecated = &t
+	}
+	return s
+}
+
+func (s *route) Sunset(t time.Time) Commenter {
+	if s.info != nil {
+		s.info.sunset = t
+	}
+	return s
+}
+
+func (s *route) BodyLimit(n int64) Commenter {
+	if s.info != nil {
+		s.info.bodyLimit = n
+	}
+	return s
 }
 
 func (s *route) Use(handler ...gin.HandlerFunc) Router {
@@ -77,15 +192,33 @@ func (s *route) Use(handler ...gin.HandlerFunc) Router {
 
 func (s *route) Group(path string, handler ...gin.HandlerFunc) GroupCommenter {
 	r := s.r.(gin.IRouter).Group(path, handler...)
+	info := s.opt.routes.addGroup(r.BasePath())
+	info.version = s.version
+	// 嵌套的分组继承上级分组的认证 权限 限流等设置
+	if s.isDir {
+		info.parent = s.info
+	}
 	return &route{
 		opt:      s.opt,
 		r:        r,
 		basepath: r.BasePath(),
 		isDir:    true,
-		info:     s.opt.routes.addGroup(r.BasePath()),
+		info:     info,
+		version:  s.version,
 	}
 }
 
+func (s *route) Version(v string) GroupCommenter {
+	g := s.Group("/" + v).(*route)
+	g.version, g.info.version = v, v
+	s.opt.addVersion(v, g.basepath)
+	return g
+}
+
+func (s *route) Controller(c any) GroupCommenter {
+	return s.registerController(c)
+}
+
 func (s *route) Handle(method, path string, handler ...any) Commenter {
 	if strings.Contains(path, "*") {
 		panic("rpc handler not support *path")
@@ -93,43 +226,97 @@ func (s *route) Handle(method, path string, handler ...any) Commenter {
 	hs := make([]gin.HandlerFunc, len(handler))
 	var info *routeInfo
 	for i, h := range handler {
-		ginFunc, ok := h.(func(*gin.Context))
-		if ok {
+		switch ginFunc := h.(type) {
+		case func(*gin.Context):
 			hs[i] = ginFunc
-		} else {
+		case gin.HandlerFunc:
+			hs[i] = ginFunc
+		default:
 			if info != nil {
 				panic("handle only support one rpc handler")
 			}
 			// 添加到路由信息表 为了自动生成doc
 			info = s.opt.routes.addRoute(s.basepath, path, h, method)
-			// 使用handleWarpf 转为gin.HandleFunc
-			hs[i] = handleWarpf(s.opt)(h)
+			if info != nil {
+				info.version = s.version
+			}
+			// 转为gin.HandleFunc
+			hs[i] = warpHandler(s.opt, info, h)
 		}
 	}
 	s.r.Handle(method, path, hs...)
+	if info != nil {
+		// 分组的中间件在rpc方法之前执行 需要通过路径找到路由
+		s.opt.routeIndex[method+" "+joinPaths(s.basepath, path)] = info
+	}
 	if info != nil {
 		return &route{info: info}
 	}
 	return nil
 }
 
+// joinPaths 与gin计算路由完整路径的方式一致
+func joinPaths(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	p := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(p, "/") {
+		return p + "/"
+	}
+	return p
+}
+
 type routeInfo struct {
 	isDir    bool
 	basePath string
 	path     string
 	comment  string
+	// 文档中的描述 来自注释
+	description string
 	// handle only
 	pcName  string
 	method  string
 	funType reflect.Value
+	// websocket路由
+	websocket bool
+	// 反向代理 不生成文档
+	proxy bool
+	// 静态文件 不生成文档
+	static bool
+	// 所属分组
+	parent *routeInfo
+	// 认证方式 nil表示继承分组的设置
+	auth []string
+	// 需要的权限
+	requires []string
+	// 限流策略
+	limits []string
+	// 超时时间 0表示继承分组的设置
+	timeout time.Duration
+	// 请求/响应体输出 nil表示继承分组的设置
+	dump *DumpOption
+	// 所属的版本
+	version string
+	// 废弃时间 nil表示未废弃 零值表示未指定时间
+	deprecated *time.Time
+	// 下线时间
+	sunset time.Time
+	// 请求体最大字节数 0表示继承分组的设置
+	bodyLimit int64
 	// dir only
 	children Routes
 }
 
 type Routes []*routeInfo
 
+// funcName 函数的运行时名称 方法值的名称以-fm结尾 去掉后与方法表达式一致
+func funcName(h any) string {
+	return strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name(), "-fm")
+}
+
 func (r *Routes) addRoute(basepath, path string, h any, method string) *routeInfo {
-	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	name := funcName(h)
 	// ns := strings.Split(name, "/")
 	info := &routeInfo{
 		path:     path,
@@ -139,9 +326,12 @@ func (r *Routes) addRoute(basepath, path string, h any, method string) *routeInf
 		method:  method,
 		funType: reflect.ValueOf(h),
 	}
+	// 通过cmd/igodoc生成的注释
+	info.comment, info.description = lookupDocComment(name)
 	if basepath != "" {
 		for k, v := range *r {
 			if v.isDir && v.basePath == basepath {
+				info.parent = v
 				(*r)[k].children = append((*r)[k].children, info)
 				return info
 			}
@@ -163,6 +353,88 @@ func (r *Routes) addGroup(path string) *routeInfo {
 	return info
 }
 
+// ancestors 路由及其所有上级分组 从最外层的分组开始
+func (r *routeInfo) ancestors() []*routeInfo {
+	var list []*routeInfo
+	for p := r; p != nil; p = p.parent {
+		list = append(list, p)
+	}
+	slices.Reverse(list)
+	return list
+}
+
+// authSchemes 路由生效的认证方式 未声明时使用最近的上级分组的
+func (r *routeInfo) authSchemes() []string {
+	for p := r; p != nil; p = p.parent {
+		if p.auth != nil {
+			return p.auth
+		}
+	}
+	return nil
+}
+
+// permissions 路由需要的全部权限 包含所有上级分组上声明的
+func (r *routeInfo) permissions() []string {
+	var list []string
+	for _, p := range r.ancestors() {
+		list = append(list, p.requires...)
+	}
+	return list
+}
+
+// rateLimits 路由生效的限流策略 包含所有上级分组上声明的
+func (r *routeInfo) rateLimits() []string {
+	var list []string
+	for _, p := range r.ancestors() {
+		list = append(list, p.limits...)
+	}
+	return list
+}
+
+// timeoutValue 路由生效的超时时间 未声明时使用最近的上级分组的
+func (r *routeInfo) timeoutValue() time.Duration {
+	for p := r; p != nil; p = p.parent {
+		if p.timeout != 0 {
+			return p.timeout
+		}
+	}
+	return 0
+}
+
+// dumpValue 路由生效的输出设置 nil表示使用全局设置
+func (r *routeInfo) dumpValue() *DumpOption {
+	for p := r; p != nil; p = p.parent {
+		if p.dump != nil {
+			return p.dump
+		}
+	}
+	return nil
+}
+
+// access 路由表中显示的认证和权限信息
+func (r *routeInfo) access() string {
+	var parts []string
+	if v := r.authSchemes(); len(v) > 0 {
+		parts = append(parts, "auth="+strings.Join(v, "|"))
+	}
+	if v := r.permissions(); len(v) > 0 {
+		parts = append(parts, "require="+strings.Join(v, ","))
+	}
+	if v := r.rateLimits(); len(v) > 0 {
+		parts = append(parts, "ratelimit="+strings.Join(v, ","))
+	}
+	if v := r.timeoutValue(); v > 0 {
+		parts = append(parts, "timeout="+v.String())
+	}
+	if v := r.dumpValue(); v != nil && v.enabled() {
+		parts = append(parts, "dump")
+	}
+	if _, ok := r.deprecation(); ok {
+		parts = append(parts, "deprecated")
+	}
+	return strings.Join(parts, " ")
+}
+
 func (r *Routes) echo() {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.DiscardEmptyColumns)
 	for _, v := range *r {
@@ -170,12 +442,12 @@ func (r *Routes) echo() {
 			if len(v.children) == 0 {
 				continue
 			}
-			fmt.Fprintf(w, "[router]├── %s\t\t\t%s\n", v.basePath, v.comment)
+			fmt.Fprintf(w, "[router]├── %s\t\t\t%s\t%s\n", v.basePath, v.access(), v.comment)
 			for _, h := range v.children {
-				fmt.Fprintf(w, "[router]│   └── %s\t%s\t%s\t%s\n", h.path, h.method, h.pcName, h.comment)
+				fmt.Fprintf(w, "[router]│   └── %s\t%s\t%s\t%s\t%s\n", h.path, h.method, h.pcName, h.access(), h.comment)
 			}
 		} else {
-			fmt.Fprintf(w, "[router]├── %s\t%s\t%s\t%s\n", v.path, v.method, v.pcName, v.comment)
+			fmt.Fprintf(w, "[router]├── %s\t%s\t%s\t%s\t%s\n", v.path, v.method, v.pcName, v.access(), v.comment)
 		}
 	}
 	w.Flush()
@@ -1,12 +1,13 @@
 package web
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"reflect"
+	"sync"
 	"time"
 
 	"log/slog"
@@ -16,6 +17,7 @@ import (
 	"github.com/gin-gonic/gin/binding"
 	"github.com/parkingwang/igo/pkg/http/code"
 	"github.com/parkingwang/igo/pkg/http/web/oas"
+	"github.com/parkingwang/igo/pkg/requestid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -28,6 +30,13 @@ type Server struct {
 	opt     *option
 	e       *gin.Engine
 	httpsrv *http.Server
+	// Start后创建的监听 Shutdown时统一关闭
+	listeners []listener
+
+	prepareOnce sync.Once
+	prepareErr  error
+	// 停止反向代理的健康检查
+	stopHealthCheck context.CancelFunc
 }
 
 func (g *Server) Route(f func(*gin.Engine, Handler)) {
@@ -42,23 +51,24 @@ func New(opts ...Option) *Server {
 	gin.SetMode(gin.ReleaseMode)
 	e := gin.New()
 	e.ContextWithFallback = true
+	e.MaxMultipartMemory = opt.bodyLimit.MaxMultipartMemory
 	e.NoRoute(func(ctx *gin.Context) {
+		if serveStatics(ctx, opt.statics) {
+			return
+		}
 		opt.render(ctx, nil, code.NewNotfoundError("route not found"))
 	})
 	e.Use(
+		func(c *gin.Context) {
+			c.Set(optionKey, opt)
+		},
 		middleware("apiservice"),
-		gin.CustomRecovery(func(c *gin.Context, err any) {
-			slog.LogAttrs(c, slog.LevelError, "gin.panic", slog.Any("err", err))
-			c.Abort()
-			opt.render(c, nil,
-				code.NewCodeError(
-					http.StatusInternalServerError,
-					"%s", http.StatusText(http.StatusInternalServerError),
-				),
-			)
-		}),
+		recovery(opt),
+		bodyLimitHandler(opt),
 	)
 
+	e.Use(opt.middlewares...)
+
 	if opt.pprof {
 		pprof.Register(e)
 	}
@@ -67,48 +77,142 @@ func New(opts ...Option) *Server {
 		opt: opt,
 		e:   e,
 		httpsrv: &http.Server{
-			Handler: e,
+			Handler:           e,
+			ReadHeaderTimeout: opt.timeout.ReadHeader,
+			ReadTimeout:       opt.timeout.Read,
+			WriteTimeout:      opt.timeout.Write,
+			IdleTimeout:       opt.timeout.Idle,
 		},
 	}
 }
 
 func (s *Server) Start(ctx context.Context) error {
+	h, err := s.Handler()
+	if err != nil {
+		return err
+	}
+	s.httpsrv.Handler = h
 	s.opt.routes.echo()
-	{
-		if s.opt.docInfo != nil {
-			docspec, err := s.opt.routes.ToDoc(*s.opt.docInfo)
-			if err != nil {
-				slog.Error("build openapi3.0 failed", "err", err)
-			}
-			e := s.GinEngine()
-			e.GET("/debug/doc", func(ctx *gin.Context) {
-				ctx.Header("Content-Type", "text/html")
-				ctx.Writer.Write(swaggerUIData)
-			})
-			e.GET("/debug/doc/swagger.json", func(ctx *gin.Context) {
-				docspec.Servers = []oas.Server{
-					{Url: "http://" + ctx.Request.Host},
-				}
-				ctx.IndentedJSON(http.StatusOK, docspec)
-			})
+
+	hctx, cancel := context.WithCancel(context.Background())
+	s.stopHealthCheck = cancel
+	for _, p := range s.opt.proxies {
+		go p.healthCheck(hctx)
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	if s.opt.tls != nil {
+		conf, err := newTLSConfig(*s.opt.tls)
+		if err != nil {
+			return err
 		}
+		s.httpsrv.TLSConfig = conf
+	}
+	if s.opt.h2c {
+		protocols.SetUnencryptedHTTP2(true)
 	}
+	s.httpsrv.Protocols = protocols
 
-	l, err := net.Listen("tcp", s.opt.addr)
+	specs := s.opt.listens
+	if len(specs) == 0 {
+		specs = []string{s.opt.addr}
+	}
+	listeners, err := listenAll(specs, s.opt.tls != nil)
 	if err != nil {
 		return err
 	}
+	s.listeners = listeners
+	for _, l := range listeners {
+		if l.tls {
+			slog.InfoContext(ctx, "Starting HTTPS server", slog.String("addr", l.spec))
+			go s.httpsrv.ServeTLS(l, "", "")
+		} else {
+			slog.InfoContext(ctx, "Starting HTTP server", slog.String("addr", l.spec), slog.Bool("h2c", s.opt.h2c))
+			go s.httpsrv.Serve(l)
+		}
+	}
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	for _, l := range s.listeners {
+		slog.InfoContext(ctx, "Shutdown HTTP server", slog.String("addr", l.spec))
+	}
+	// Shutdown不会处理已经被劫持的连接 需要主动关闭websocket
+	s.opt.wsconns.closeAll()
+	if s.stopHealthCheck != nil {
+		s.stopHealthCheck()
+	}
+	return s.httpsrv.Shutdown(ctx)
+}
+
+// Handler 检查路由并注册文档等内置路由后返回http.Handler 不会监听端口
+// Start时自动调用 也可以用于测试或者挂载到其他http服务
+func (s *Server) Handler() (http.Handler, error) {
+	s.prepareOnce.Do(func() {
+		s.prepareErr = s.prepare()
+	})
+	if s.opt.versioning.enabled() {
+		return versionHandler(s.opt, s.e), s.prepareErr
+	}
+	return s.e, s.prepareErr
+}
+
+func (s *Server) prepare() error {
+	if err := s.opt.routes.checkRoutes(s.opt); err != nil {
+		return err
+	}
+	if s.opt.docInfo != nil {
+		s.serveDoc("/debug/doc", "")
+		// 每个版本单独的文档
+		for _, v := range s.opt.versions {
+			s.serveDoc("/debug/doc/"+v.name, v.name)
+		}
+	}
 	// 关闭gin默认的校验
 	// 等待所有都读取完成后统一校验
 	binding.Validator = nil
-	slog.InfoContext(ctx, "Starting HTTP server", slog.String("addr", s.opt.addr))
-	go s.httpsrv.Serve(l)
 	return nil
 }
 
-func (s *Server) Stop(ctx context.Context) error {
-	slog.InfoContext(ctx, "Shutdown HTTP server", slog.String("addr", s.opt.addr))
-	return s.httpsrv.Shutdown(ctx)
+// serveDoc 注册文档页面和swagger.json version为空时包含所有路由
+func (s *Server) serveDoc(path, version string) {
+	docspec, err := s.OpenAPI(version)
+	if err != nil {
+		slog.Error("build openapi3.0 failed", "err", err, "version", version)
+	}
+	page := bytes.Replace(swaggerUIData, []byte("/debug/doc/swagger.json"), []byte(path+"/swagger.json"), 1)
+	e := s.GinEngine()
+	e.GET(path, func(ctx *gin.Context) {
+		ctx.Header("Content-Type", "text/html")
+		ctx.Writer.Write(page)
+	})
+	e.GET(path+"/swagger.json", func(ctx *gin.Context) {
+		scheme := "http://"
+		if ctx.Request.TLS != nil {
+			scheme = "https://"
+		}
+		docspec.Servers = []oas.Server{
+			{Url: scheme + ctx.Request.Host},
+		}
+		ctx.IndentedJSON(http.StatusOK, docspec)
+	})
+}
+
+// OpenAPI 根据已注册的路由生成文档 未设置WithOpenAPI时使用空的DocInfo
+// 指定version时只包含Router.Version注册的此版本的路由
+func (s *Server) OpenAPI(version ...string) (*oas.Spec, error) {
+	var info oas.DocInfo
+	if s.opt.docInfo != nil {
+		info = *s.opt.docInfo
+	}
+	var v string
+	if len(version) > 0 {
+		v = version[0]
+	}
+	return s.opt.routes.toDoc(info, s.opt, v)
 }
 
 // Router rpc风格的路由
@@ -130,15 +234,15 @@ func GinContext(ctx context.Context) (*gin.Context, bool) {
 	return c, ok
 }
 
-var errHandleType = errors.New("rpc handle must func(ctx context.Context, in *struct/slice)(out any,err error) type")
+var errHandleType = errors.New("rpc handle must func(ctx context.Context, in *struct/slice, deps...)(out any,err error) type")
 
 func checkHandleValid(tp reflect.Type) (int, bool) {
 	if tp.Kind() != reflect.Func {
 		return 0, false
 	}
 
-	// check request
-	if !(tp.NumIn() == 2 &&
+	// check request 第三个参数开始为依赖 通过WithValues/WithProvider注册
+	if !(tp.NumIn() >= 2 &&
 		rtypeContext.Implements(tp.In(0)) &&
 		((tp.In(1).Kind() == reflect.Ptr && tp.In(1).Elem().Kind() == reflect.Struct) || tp.In(1).Kind() == reflect.Slice)) {
 		return 0, false
@@ -160,65 +264,118 @@ func checkHandleValid(tp reflect.Type) (int, bool) {
 
 func handleWarpf(opt *option) Handler {
 	return func(iface any) gin.HandlerFunc {
-		tp := reflect.TypeOf(iface)
-		numOut, ok := checkHandleValid(tp)
-		if !ok {
-			panic(errHandleType)
+		return warpHandler(opt, nil, iface)
+	}
+}
+
+// warpHandler 将rpc方法转为gin.HandlerFunc
+// info 为路由信息 请求时读取路由上声明的认证等设置 可以为nil
+func warpHandler(opt *option, info *routeInfo, iface any) gin.HandlerFunc {
+	tp := reflect.TypeOf(iface)
+	numOut, ok := checkHandleValid(tp)
+	if !ok {
+		panic(errHandleType)
+	}
+	var (
+		method        = reflect.ValueOf(iface)
+		isSlice       = tp.In(1).Kind() != reflect.Ptr
+		isStream      = numOut == 2 && isStreamType(tp.Out(0))
+		isFile        = numOut == 2 && tp.Out(0) == rtypeFileResponse
+		fileRules     []fileRule
+		tags          = make(map[string]bool)
+		reqParamsType reflect.Type
+	)
+
+	if !isSlice {
+		reqParamsType = tp.In(1).Elem()
+	} else {
+		reqParamsType = tp.In(1)
+	}
+	deepfindTags(reqParamsType, tags)
+	if !isSlice {
+		fileRules = parseFileRules(reqParamsType, nil, nil)
+	}
+	var dumpTypes []reflect.Type
+	if numOut == 2 && !isStream && !isFile {
+		dumpTypes = append(dumpTypes, tp.Out(0))
+	}
+	dumpers := newDumperCache(append(dumpTypes, reqParamsType)...)
+	providers := handlerProviders(opt, tp)
+	return func(ctx *gin.Context) {
+		if d := dumpers.get(opt, info); d != nil {
+			defer d.begin(ctx)()
 		}
-		var (
-			method        = reflect.ValueOf(iface)
-			isSlice       = tp.In(1).Kind() != reflect.Ptr
-			tags          = make(map[string]bool)
-			reqParamsType reflect.Type
-		)
-
-		if !isSlice {
-			reqParamsType = tp.In(1).Elem()
-		} else {
-			reqParamsType = tp.In(1)
+		defer withDeadline(ctx, opt, info, isStream)()
+		deprecationHeaders(ctx, info)
+		if err := guard(ctx, opt, info); err != nil {
+			warpRender(opt, ctx, nil, err)
+			return
 		}
-		deepfindTags(reqParamsType, tags)
-		return func(ctx *gin.Context) {
-			q := reflect.New(reqParamsType)
-			if isSlice {
-				q.Elem().Set(reflect.MakeSlice(reqParamsType, 0, 0))
-			}
-			if reqParamsType != rtypEempty {
-				var err error
-				qinface, ok := ctx.Get(custombindkey)
-				if ok && !isSlice {
-					q.Elem().Set(reflect.ValueOf(qinface).Elem())
-				} else {
-					qinface = q.Interface()
-					err = checkReqParam(ctx, qinface, tags)
-				}
-				// 输出请求体
-				if opt.dumpRequestBody {
-					slog.LogAttrs(ctx, slog.LevelInfo, "gin.dumpRequest", slog.Any("data", q))
+		q := reflect.New(reqParamsType)
+		if isSlice {
+			q.Elem().Set(reflect.MakeSlice(reqParamsType, 0, 0))
+		}
+		if reqParamsType != rtypEempty {
+			var err error
+			qinface, ok := ctx.Get(custombindkey)
+			if ok && !isSlice {
+				q.Elem().Set(reflect.ValueOf(qinface).Elem())
+			} else {
+				qinface = q.Interface()
+				err = limitBody(ctx, opt, info)
+				if err == nil {
+					err = checkJSONLimits(ctx, opt, tags["json"])
 				}
-				if err == nil && !isSlice {
-					err = opt.bind.Struct(qinface)
-				}
-				if err != nil {
-					warpRender(opt, ctx, nil, code.NewBadRequestError(err))
-					return
+				if err == nil {
+					err = checkReqParam(ctx, qinface, tags, opt.codecs)
 				}
 			}
-			if isSlice {
-				q = q.Elem()
+			if err == nil && !isSlice {
+				err = opt.bind.Struct(qinface)
 			}
-			// 反射调用真实的函数
-			ret := method.Call([]reflect.Value{reflect.ValueOf(ctx), q})
-			if e := ret[numOut-1].Interface(); e != nil {
-				warpRender(opt, ctx, nil, e.(error))
+			if err != nil {
+				warpRender(opt, ctx, nil, bindError(err))
 				return
 			}
-			if numOut == 2 {
-				warpRender(opt, ctx, ret[0].Interface(), nil)
-			} else {
-				warpRender(opt, ctx, nil, nil)
+			// 上传文件的错误已经带有413/415状态码
+			if len(fileRules) > 0 {
+				if err := checkFiles(q, fileRules); err != nil {
+					warpRender(opt, ctx, nil, err)
+					return
+				}
 			}
 		}
+		if isSlice {
+			q = q.Elem()
+		}
+		args, err := resolveArgs(ctx, providers, []reflect.Value{reflect.ValueOf(ctx), q})
+		if err != nil {
+			warpRender(opt, ctx, nil, err)
+			return
+		}
+		// 反射调用真实的函数
+		ret := method.Call(args)
+		if e := ret[numOut-1].Interface(); e != nil {
+			warpRender(opt, ctx, nil, timeoutError(ctx, e.(error)))
+			return
+		}
+		if isFile {
+			if f := ret[0].Interface().(*FileResponse); f != nil {
+				serveFile(ctx, f)
+			}
+			return
+		}
+		if isStream {
+			if !ret[0].IsNil() {
+				serveStream(opt, ctx, ret[0])
+			}
+			return
+		}
+		if numOut == 2 {
+			warpRender(opt, ctx, ret[0].Interface(), nil)
+		} else {
+			warpRender(opt, ctx, nil, nil)
+		}
 	}
 }
 
@@ -238,10 +395,18 @@ func middleware(service string) gin.HandlerFunc {
 		}
 
 		ctx := txtpropagator.Extract(savedCtx, propagation.HeaderCarrier(c.Request.Header))
+		// 请求ID 使用上游传入的或者新生成
+		requestID := c.GetHeader(requestid.Header)
+		if !requestid.Valid(requestID) {
+			requestID = requestid.New()
+		}
+		ctx = requestid.NewContext(ctx, requestID)
+		c.Header(requestid.Header, requestID)
 		opts := []trace.SpanStartOption{
 			trace.WithAttributes(semconv.NetAttributesFromHTTPRequest("tcp", c.Request)...),
 			trace.WithAttributes(semconv.EndUserAttributesFromHTTPRequest(c.Request)...),
 			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(service, c.FullPath(), c.Request)...),
+			trace.WithAttributes(attribute.String("http.request_id", requestID)),
 			trace.WithSpanKind(trace.SpanKindServer),
 		}
 		spanName := c.FullPath()
@@ -284,6 +449,9 @@ func middleware(service string) gin.HandlerFunc {
 				slog.String("response.error", rerr),
 			)
 		}
+		if v, ok := c.Get(accessLogAttrsKey); ok {
+			logattrs = append(logattrs, v.([]slog.Attr)...)
+		}
 
 		slog.LogAttrs(ctx, loglvl, "gin.access", logattrs...)
 	}
@@ -291,6 +459,33 @@ func middleware(service string) gin.HandlerFunc {
 
 var custombindkey = "_igo_custom_bind"
 
+const optionKey = "_igo_option"
+
+// routeFrom 返回当前请求匹配的rpc路由 gin中间件中也可以获取
+func routeFrom(c *gin.Context, opt *option) *routeInfo {
+	return opt.routeIndex[c.Request.Method+" "+c.FullPath()]
+}
+
+const accessLogAttrsKey = "_igo_access_attrs"
+
+// AddAccessLogAttrs 向当前请求的访问日志中添加字段 用于中间件记录额外信息
+func AddAccessLogAttrs(c *gin.Context, attrs ...slog.Attr) {
+	if v, ok := c.Get(accessLogAttrsKey); ok {
+		attrs = append(v.([]slog.Attr), attrs...)
+	}
+	c.Set(accessLogAttrsKey, attrs)
+}
+
+// optionFrom 返回当前请求所属server的配置
+func optionFrom(c *gin.Context) (*option, bool) {
+	v, ok := c.Get(optionKey)
+	if !ok {
+		return nil, false
+	}
+	opt, ok := v.(*option)
+	return opt, ok
+}
+
 // CustomBindRequest 自定义绑定参数 注意不包含验证 验证还是会统一进行
 func CustomBindRequest[T any](f func(c *gin.Context) T) func(c *gin.Context) {
 	x := reflect.TypeOf(f)
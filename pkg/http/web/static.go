@@ -0,0 +1,309 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StaticOption 静态文件设置
+type StaticOption struct {
+	// SPA 文件不存在时返回Index 仅对没有扩展名且Accept包含text/html的请求生效
+	SPA bool
+	// Index 目录的默认文件 默认index.html
+	Index string
+	// Browse 列出没有Index的目录 默认关闭
+	Browse bool
+	// CacheControl 根据文件路径返回Cache-Control 默认html为no-cache 其他为public, max-age=3600
+	CacheControl func(name string) string
+}
+
+// staticEncodings 预压缩文件的后缀 按优先级排列
+var staticEncodings = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+type staticFS struct {
+	fsys   fs.FS
+	prefix string
+	opt    StaticOption
+	srvopt *option
+	info   *routeInfo
+	// 文件内容的hash 用于ETag
+	etags sync.Map
+}
+
+func newStaticFS(srvopt *option, prefix string, fsys fs.FS, opt StaticOption) *staticFS {
+	if opt.Index == "" {
+		opt.Index = "index.html"
+	}
+	if opt.CacheControl == nil {
+		opt.CacheControl = defaultCacheControl
+	}
+	return &staticFS{fsys: fsys, prefix: prefix, opt: opt, srvopt: srvopt}
+}
+
+func defaultCacheControl(name string) string {
+	if path.Ext(name) == ".html" {
+		return "no-cache"
+	}
+	return "public, max-age=3600"
+}
+
+// match 请求路径在prefix下时返回文件名
+func (s *staticFS) match(p string) (string, bool) {
+	if s.prefix != "" {
+		if p != s.prefix && !strings.HasPrefix(p, s.prefix+"/") {
+			return "", false
+		}
+		p = p[len(s.prefix):]
+	}
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+// serve 返回false表示文件不存在 未写入响应
+func (s *staticFS) serve(c *gin.Context, name string) bool {
+	file, info, dir, ok := s.lookup(c.Request, name)
+	if !ok {
+		return false
+	}
+	deprecationHeaders(c, s.info)
+	if err := guard(c, s.srvopt, s.info); err != nil {
+		warpRender(s.srvopt, c, nil, err)
+		return true
+	}
+	// 目录需要以/结尾 否则页面中的相对路径会指向上一级目录 与http.FileServer一致
+	if p := c.Request.URL.Path; dir && !strings.HasSuffix(p, "/") {
+		target := path.Base(p) + "/"
+		if q := c.Request.URL.RawQuery; q != "" {
+			target += "?" + q
+		}
+		c.Header("Location", target)
+		c.Status(http.StatusMovedPermanently)
+		return true
+	}
+	if info.IsDir() {
+		s.browse(c, file)
+		return true
+	}
+	return s.serveFile(c, file, info)
+}
+
+// lookup 返回需要输出的文件以及请求的是否为目录 目录只在开启Browse时返回
+func (s *staticFS) lookup(r *http.Request, name string) (string, fs.FileInfo, bool, bool) {
+	info, err := fs.Stat(s.fsys, name)
+	if err == nil && info.IsDir() {
+		index := path.Join(name, s.opt.Index)
+		if fi, err := fs.Stat(s.fsys, index); err == nil && !fi.IsDir() {
+			return index, fi, true, true
+		}
+		if s.opt.Browse {
+			return name, info, true, true
+		}
+		err = fs.ErrNotExist
+	}
+	if err == nil {
+		return name, info, false, true
+	}
+	if s.opt.SPA && path.Ext(name) == "" && acceptHTML(r) {
+		if fi, err := fs.Stat(s.fsys, s.opt.Index); err == nil && !fi.IsDir() {
+			return s.opt.Index, fi, false, true
+		}
+	}
+	return "", nil, false, false
+}
+
+func acceptHTML(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return accept == "" || strings.Contains(accept, "text/html")
+}
+
+// serveFile 优先使用预压缩的文件 Range/If-None-Match/If-Modified-Since由http.ServeContent处理
+func (s *staticFS) serveFile(c *gin.Context, name string, info fs.FileInfo) bool {
+	h := c.Writer.Header()
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	h.Set("Content-Type", ctype)
+	h.Set("Cache-Control", s.opt.CacheControl(name))
+
+	file, encoding := name, ""
+	accept := acceptEncodings(c.GetHeader("Accept-Encoding"))
+	for _, e := range staticEncodings {
+		if !accept(e.encoding) {
+			continue
+		}
+		if fi, err := fs.Stat(s.fsys, name+e.ext); err == nil && !fi.IsDir() {
+			file, encoding, info = name+e.ext, e.encoding, fi
+			break
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+
+	f, err := s.fsys.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			c.Error(err)
+			c.Status(http.StatusInternalServerError)
+			return true
+		}
+		rs = bytes.NewReader(b)
+	}
+	etag, err := s.etag(file, info, rs)
+	if err != nil {
+		c.Error(err)
+		c.Status(http.StatusInternalServerError)
+		return true
+	}
+	h.Set("ETag", etag)
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	clearWriteDeadline(c.Writer)
+	// embed.FS的修改时间为零值 此时只使用ETag
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), rs)
+	return true
+}
+
+// acceptEncodings 解析Accept-Encoding 返回是否接受某个编码 q=0表示不接受
+func acceptEncodings(header string) func(encoding string) bool {
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		qs[name] = q
+	}
+	return func(encoding string) bool {
+		if q, ok := qs[encoding]; ok {
+			return q > 0
+		}
+		return qs["*"] > 0
+	}
+}
+
+// etag 文件内容的sha256 按文件名/大小/修改时间缓存
+func (s *staticFS) etag(name string, info fs.FileInfo, rs io.ReadSeeker) (string, error) {
+	key := fmt.Sprintf("%s|%d|%d", name, info.Size(), info.ModTime().UnixNano())
+	if v, ok := s.etags.Load(key); ok {
+		return v.(string), nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, rs); err != nil {
+		return "", err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	s.etags.Store(key, etag)
+	return etag, nil
+}
+
+// browse 简单的目录列表
+func (s *staticFS) browse(c *gin.Context, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		c.Error(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() {
+			n += "/"
+		}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(n), html.EscapeString(n))
+	}
+	b.WriteString("</pre>\n")
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(b.String()))
+}
+
+// handler 文件不存在时输出纯文本的404 不使用rpc的错误格式
+func (s *staticFS) handler(c *gin.Context) {
+	name, ok := s.match(c.Request.URL.Path)
+	if ok && s.serve(c, name) {
+		return
+	}
+	c.String(http.StatusNotFound, "404 page not found")
+}
+
+func (s *route) Static(prefix string, fsys fs.FS, opts ...StaticOption) Commenter {
+	var o StaticOption
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	st := newStaticFS(s.opt, s.basepath+prefix, fsys, o)
+
+	info := s.opt.routes.addRoute(s.basepath, prefix+"/*filepath", st.handler, http.MethodGet)
+	if info != nil {
+		info.static = true
+		info.version = s.version
+		info.pcName = "static"
+		info.comment = ""
+	}
+	st.info = info
+	// 根路径下的通配符会与其他路由冲突 在NoRoute中处理
+	if s.basepath+prefix == "" {
+		s.opt.statics = append(s.opt.statics, st)
+		return &route{info: info}
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		s.r.Handle(method, prefix, st.handler)
+		s.r.Handle(method, prefix+"/*filepath", st.handler)
+	}
+	return &route{info: info}
+}
+
+// serveStatics NoRoute时尝试根路径的静态文件 返回是否已处理
+func serveStatics(c *gin.Context, statics []*staticFS) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+	for _, st := range statics {
+		if name, ok := st.match(c.Request.URL.Path); ok && st.serve(c, name) {
+			return true
+		}
+	}
+	return false
+}
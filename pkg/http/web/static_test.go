@@ -0,0 +1,71 @@
+package web_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"testing/fstest"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/webtest"
+)
+
+func TestStatic(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":       {Data: []byte("<html>app</html>")},
+		"assets/app.js":    {Data: []byte("console.log(1)")},
+		"assets/app.js.br": {Data: []byte("br-data")},
+		"docs/a.txt":       {Data: []byte("a")},
+	}
+	s := webtest.New(t, func(r web.Router) {
+		r.Get("/api/user/:id", getUser)
+		r.Static("/", files, web.StaticOption{SPA: true})
+		r.Static("/files", files, web.StaticOption{Browse: true})
+	})
+	res := s.Get("/assets/app.js").ExpectStatus(http.StatusOK).
+		ExpectHeader("Cache-Control", "public, max-age=3600").
+		ExpectBodyContains("console.log")
+	etag := res.Header().Get("ETag")
+	s.Get("/assets/app.js").WithHeader("If-None-Match", etag).ExpectStatus(http.StatusNotModified)
+	s.Get("/assets/app.js").WithHeader("Accept-Encoding", "gzip, br").ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Encoding", "br").
+		ExpectHeader("Content-Type", "text/javascript; charset=utf-8").
+		ExpectBodyContains("br-data")
+	s.Get("/assets/app.js").WithHeader("Accept-Encoding", "*").Expect().ExpectHeader("Content-Encoding", "br")
+	// q=0表示不接受
+	for _, accept := range []string{"br;q=0, gzip", "gzip, *;q=0", "br; q=0.0"} {
+		s.Get("/assets/app.js").WithHeader("Accept-Encoding", accept).ExpectStatus(http.StatusOK).
+			ExpectHeader("Content-Encoding", "").
+			ExpectBodyContains("console.log")
+	}
+
+	// SPA 前端路由返回index.html 缺少的资源和api仍然是404
+	s.Get("/orders/1").WithHeader("Accept", "text/html").ExpectStatus(http.StatusOK).
+		ExpectHeader("Cache-Control", "no-cache").
+		ExpectBodyContains("<html>app</html>")
+	s.Get("/assets/missing.js").ExpectStatus(http.StatusNotFound)
+	s.Get("/api/missing").WithHeader("Accept", "application/json").ExpectStatus(http.StatusNotFound).
+		ExpectBodyContains("route not found")
+	s.Get("/api/user/1").ExpectStatus(http.StatusOK)
+
+	// 目录不以/结尾时重定向 页面中的相对路径才能指向目录下的文件
+	follow := func(base, ref string) string {
+		u, _ := url.Parse(base)
+		r, _ := url.Parse(ref)
+		return u.ResolveReference(r).String()
+	}
+	loc := s.Get("/files/docs").ExpectStatus(http.StatusMovedPermanently).Header().Get("Location")
+	dir := follow("/files/docs", loc)
+	s.Get(dir).ExpectStatus(http.StatusOK).ExpectBodyContains(`<a href="a.txt">`)
+	s.Get(follow(dir, "a.txt")).ExpectStatus(http.StatusOK).ExpectBodyContains("a")
+	s.Get("/files").ExpectStatus(http.StatusMovedPermanently).ExpectHeader("Location", "files/")
+	s.Get("/files/nope.txt").ExpectStatus(http.StatusNotFound).ExpectBodyContains("404 page not found")
+
+	spec, err := s.Server().OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Paths) != 1 {
+		t.Fatalf("static routes should not in doc %v", spec.Paths)
+	}
+}
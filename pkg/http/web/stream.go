@@ -0,0 +1,157 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event SSE消息
+// 当rpc方法返回 <-chan Event 时可以控制消息的 id/event/retry
+// 其他类型的chan元素会被当作data输出 字符串原样输出 其他json编码
+type Event struct {
+	// 消息id 客户端断线重连时会通过 Last-Event-ID 携带
+	ID string
+	// 事件名称 为空时客户端按message处理
+	Event string
+	// 通知客户端重连的间隔
+	Retry time.Duration
+	Data  any
+}
+
+const (
+	headerLastEventID = "Last-Event-ID"
+	mimeEventStream   = "text/event-stream"
+)
+
+var rtypeEvent = reflect.TypeOf(Event{})
+
+// LastEventID 返回客户端断线重连时携带的最后一条消息id
+// 可用于从断点处继续推送
+func LastEventID(ctx context.Context) string {
+	if c, ok := GinContext(ctx); ok {
+		if id := c.GetHeader(headerLastEventID); id != "" {
+			return id
+		}
+		// 部分浏览器的EventSource polyfill无法设置header 通过query传递
+		return c.Query("lastEventId")
+	}
+	return ""
+}
+
+func isStreamType(tp reflect.Type) bool {
+	return tp.Kind() == reflect.Chan && tp.ChanDir()&reflect.RecvDir != 0
+}
+
+// serveStream 将chan中的数据以SSE的方式推送给客户端
+// 直到chan被关闭或客户端断开连接
+// 注意: 写入chan的一方需要监听ctx.Done()退出 否则会造成goroutine泄露
+func serveStream(opt *option, c *gin.Context, ch reflect.Value) {
+	w := c.Writer
+	header := w.Header()
+	header.Set("Content-Type", mimeEventStream)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 关闭nginx的缓冲 否则消息会积压
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+	clearWriteDeadline(w)
+
+	span := trace.SpanFromContext(c)
+	if id := LastEventID(c); id != "" {
+		span.SetAttributes(attribute.String("sse.last_event_id", id))
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.Done())},
+	}
+	if opt.sseHeartbeat > 0 {
+		ticker := time.NewTicker(opt.sseHeartbeat)
+		defer ticker.Stop()
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ticker.C)})
+	}
+
+	var count int
+	defer func() {
+		span.SetAttributes(attribute.Int("sse.events", count))
+	}()
+	for {
+		chosen, v, ok := reflect.Select(cases)
+		switch chosen {
+		case 0:
+			if !ok {
+				// 数据推送完毕
+				return
+			}
+			if err := writeEvent(w, v.Interface()); err != nil {
+				c.Error(err)
+				return
+			}
+			count++
+		case 1:
+			span.AddEvent("sse.client_disconnected")
+			return
+		default:
+			// 心跳 注释行会被客户端忽略 用于保持连接不被代理断开
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+var (
+	// 换行会被客户端当作新的字段 id中的NUL会导致客户端忽略此id
+	sseFieldReplacer   = strings.NewReplacer("\r", "", "\n", "", "\x00", "")
+	sseNewlineReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+)
+
+func writeEvent(w io.Writer, v any) error {
+	e, ok := v.(Event)
+	if !ok {
+		e = Event{Data: v}
+	}
+	var b strings.Builder
+	if id := sseFieldReplacer.Replace(e.ID); id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	if event := sseFieldReplacer.Replace(e.Event); event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.Retry.Milliseconds())
+	}
+	var data string
+	switch d := e.Data.(type) {
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		bs, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		data = string(bs)
+	}
+	// \r和\r\n同样是换行 统一后每行单独输出
+	data = sseNewlineReplacer.Replace(data)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,77 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+)
+
+func TestStream(t *testing.T) {
+	h := newTestServer(t, func(r web.Router) {
+		r.Get("/events", func(ctx context.Context, in *web.Empty) (<-chan web.Event, error) {
+			ch := make(chan web.Event, 3)
+			ch <- web.Event{ID: "1", Event: "greeting", Retry: time.Second, Data: "hello\nworld"}
+			// 换行不能注入新的字段
+			ch <- web.Event{ID: "2\nevent: admin", Event: "update\r\ndata: injected", Data: "a\rid: 3"}
+			ch <- web.Event{Data: map[string]int{"n": 1}}
+			close(ch)
+			return ch, nil
+		})
+	})
+
+	w := do(h, http.MethodGet, "/events", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	want := "id: 1\nevent: greeting\nretry: 1000\ndata: hello\ndata: world\n\n" +
+		"id: 2event: admin\nevent: updatedata: injected\ndata: a\ndata: id: 3\n\n" +
+		"data: {\"n\":1}\n\n"
+	if w.Body.String() != want {
+		t.Fatalf("unexpected stream:\n%q\nwant\n%q", w.Body.String(), want)
+	}
+}
+
+func TestStreamLastEventID(t *testing.T) {
+	h := newTestServer(t, func(r web.Router) {
+		r.Get("/events", func(ctx context.Context, in *web.Empty) (<-chan string, error) {
+			ch := make(chan string, 1)
+			ch <- "from " + web.LastEventID(ctx)
+			close(ch)
+			return ch, nil
+		})
+	})
+	if body := do(h, http.MethodGet, "/events", nil, "Last-Event-ID", "41").Body.String(); !strings.Contains(body, "data: from 41\n") {
+		t.Fatalf("unexpected %q", body)
+	}
+	// EventSource polyfill通过query传递
+	if body := do(h, http.MethodGet, "/events?lastEventId=42", nil).Body.String(); !strings.Contains(body, "data: from 42\n") {
+		t.Fatalf("unexpected %q", body)
+	}
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	h := newTestServer(t, func(r web.Router) {
+		r.Get("/events", func(ctx context.Context, in *web.Empty) (<-chan string, error) {
+			ch := make(chan string)
+			go func() {
+				defer close(ch)
+				select {
+				case <-time.After(time.Millisecond * 50):
+					ch <- "done"
+				case <-ctx.Done():
+				}
+			}()
+			return ch, nil
+		})
+	}, web.WithSSEHeartbeat(time.Millisecond*10))
+
+	w := do(h, http.MethodGet, "/events", nil)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, ": ping\n\n") || !strings.HasSuffix(body, "data: done\n\n") {
+		t.Fatalf("expect heartbeat before data: %d %q", w.Code, body)
+	}
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parkingwang/igo/pkg/http/code"
+)
+
+// HeaderRequestTimeout 调用方剩余的超时时间 如 1500ms / 3s 纯数字表示毫秒
+// 只会缩短路由上声明的超时时间
+const HeaderRequestTimeout = "X-Request-Timeout"
+
+// TimeoutOption 超时设置
+type TimeoutOption struct {
+	// 读取请求头
+	ReadHeader time.Duration
+	// 读取整个请求 默认不限制 设置后会影响大文件上传
+	Read time.Duration
+	// 写入响应 默认不限制 SSE/websocket/文件下载/反向代理/静态文件不受限制
+	Write time.Duration
+	// keep-alive空闲连接
+	Idle time.Duration
+	// rpc方法的默认超时时间 路由可以通过Timeout覆盖 0表示不限制
+	Handler time.Duration
+}
+
+func defaultTimeoutOption() TimeoutOption {
+	return TimeoutOption{
+		ReadHeader: time.Second * 10,
+		Idle:       time.Minute * 2,
+	}
+}
+
+// WithTimeout 超时设置 未设置的字段使用默认值
+func WithTimeout(t TimeoutOption) Option {
+	return func(o *option) {
+		if t.ReadHeader > 0 {
+			o.timeout.ReadHeader = t.ReadHeader
+		}
+		if t.Read > 0 {
+			o.timeout.Read = t.Read
+		}
+		if t.Write > 0 {
+			o.timeout.Write = t.Write
+		}
+		if t.Idle > 0 {
+			o.timeout.Idle = t.Idle
+		}
+		if t.Handler > 0 {
+			o.timeout.Handler = t.Handler
+		}
+	}
+}
+
+// withDeadline 为rpc方法设置超时时间 gorm/redis/client等使用ctx的调用会被取消
+// 流式响应只使用路由上声明的超时时间
+func withDeadline(c *gin.Context, opt *option, info *routeInfo, stream bool) context.CancelFunc {
+	var d time.Duration
+	if info != nil {
+		d = info.timeoutValue()
+	}
+	if d == 0 && !stream {
+		d = opt.timeout.Handler
+	}
+	if budget, ok := requestBudget(c.Request); ok && (d <= 0 || budget < d) {
+		d = budget
+	}
+	if d <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+	c.Request = c.Request.WithContext(ctx)
+	return cancel
+}
+
+// requestBudget 读取调用方传递的超时时间
+func requestBudget(r *http.Request) (time.Duration, bool) {
+	v := r.Header.Get(HeaderRequestTimeout)
+	if v == "" {
+		return 0, false
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, ms > 0
+	}
+	d, err := time.ParseDuration(v)
+	return d, err == nil && d > 0
+}
+
+// timeoutError 超时导致的错误统一按504输出
+func timeoutError(c *gin.Context, err error) error {
+	var ce *code.CodeError
+	if errors.Is(err, context.DeadlineExceeded) ||
+		(!errors.As(err, &ce) && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded)) {
+		return code.NewCodeError(http.StatusGatewayTimeout, "request timeout")
+	}
+	return err
+}
+
+// clearWriteDeadline 长连接的响应不受WriteTimeout限制
+func clearWriteDeadline(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
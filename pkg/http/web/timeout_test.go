@@ -0,0 +1,90 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestBudget(t *testing.T) {
+	cases := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"1500", time.Millisecond * 1500, true},
+		{"3s", time.Second * 3, true},
+		{"0", 0, false},
+		{"-1s", 0, false},
+		{"abc", 0, false},
+	}
+	for _, v := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderRequestTimeout, v.header)
+		d, ok := requestBudget(r)
+		if ok != v.ok || (ok && d != v.want) {
+			t.Errorf("%q: got %s %v, want %s %v", v.header, d, ok, v.want, v.ok)
+		}
+	}
+}
+
+func TestWithDeadline(t *testing.T) {
+	opt := defaultOption()
+	opt.timeout.Handler = time.Minute
+	group := &routeInfo{isDir: true, timeout: time.Second * 10}
+	nested := &routeInfo{isDir: true, parent: group}
+	route := &routeInfo{parent: nested}
+
+	remain := func(info *routeInfo, stream bool, budget string) time.Duration {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		if budget != "" {
+			c.Request.Header.Set(HeaderRequestTimeout, budget)
+		}
+		cancel := withDeadline(c, opt, info, stream)
+		defer cancel()
+		deadline, ok := c.Request.Context().Deadline()
+		if !ok {
+			return 0
+		}
+		return time.Until(deadline).Round(time.Second)
+	}
+
+	// 未声明时使用默认值
+	if d := remain(&routeInfo{}, false, ""); d != time.Minute {
+		t.Errorf("default timeout %s", d)
+	}
+	// 继承上上级分组的声明
+	if d := remain(route, false, ""); d != time.Second*10 {
+		t.Errorf("group timeout %s", d)
+	}
+	// 调用方只能缩短超时时间
+	if d := remain(route, false, "2s"); d != time.Second*2 {
+		t.Errorf("budget timeout %s", d)
+	}
+	if d := remain(route, false, "1m"); d != time.Second*10 {
+		t.Errorf("budget should not extend timeout %s", d)
+	}
+	// 流式响应不使用默认值
+	if d := remain(&routeInfo{}, true, ""); d != 0 {
+		t.Errorf("stream timeout %s", d)
+	}
+}
+
+func TestTimeoutStatus(t *testing.T) {
+	srv := New(WithPProf(false))
+	srv.Router().Get("/slow", func(ctx context.Context, in *Empty) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}).Timeout(time.Millisecond * 10)
+	w := httptest.NewRecorder()
+	srv.GinEngine().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expect 504, got %d: %s", w.Code, w.Body)
+	}
+}
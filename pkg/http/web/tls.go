@@ -0,0 +1,202 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"log/slog"
+)
+
+// TLSOption https配置
+// 证书文件变化后自动加载 无需重启
+type TLSOption struct {
+	CertFile string
+	KeyFile  string
+	// 客户端证书的CA 设置后启用mTLS
+	ClientCAFile string
+	// 客户端证书验证方式 request/require/verify/require_verify
+	// 设置了ClientCAFile时默认require_verify
+	ClientAuth string
+	// 最低版本 1.2/1.3 默认1.2
+	MinVersion string
+	// 允许的加密套件 如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 为空使用go的默认值 对1.3无效
+	CipherSuites []string
+}
+
+// WithTLS 启用https 同时支持http/2
+func WithTLS(t TLSOption) Option {
+	return func(o *option) {
+		o.tls = &t
+	}
+}
+
+// WithH2C 非tls的监听支持h2c(prior knowledge) 用于内部服务之间的调用
+func WithH2C(enable bool) Option {
+	return func(o *option) {
+		o.h2c = enable
+	}
+}
+
+// ClientCertificate 返回mTLS验证通过的客户端证书
+func ClientCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	c, ok := GinContext(ctx)
+	if !ok || c.Request.TLS == nil {
+		return nil, false
+	}
+	chains := c.Request.TLS.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil, false
+	}
+	return chains[0][0], true
+}
+
+// certReloader 握手时检查证书文件是否变化 变化后重新加载
+type certReloader struct {
+	opt  TLSOption
+	base *tls.Config
+
+	mu        sync.RWMutex
+	conf      *tls.Config
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+// 检查证书文件的最小间隔
+const certCheckInterval = time.Second * 10
+
+func newTLSConfig(opt TLSOption) (*tls.Config, error) {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	switch opt.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		base.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("tls: unsupported min version %s", opt.MinVersion)
+	}
+	if len(opt.CipherSuites) > 0 {
+		ids := make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			ids[s.Name] = s.ID
+		}
+		for _, name := range opt.CipherSuites {
+			id, ok := ids[name]
+			if !ok {
+				return nil, fmt.Errorf("tls: unsupported or insecure cipher suite %s", name)
+			}
+			base.CipherSuites = append(base.CipherSuites, id)
+		}
+	}
+	switch strings.ToLower(opt.ClientAuth) {
+	case "":
+		if opt.ClientCAFile != "" {
+			base.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	case "request":
+		base.ClientAuth = tls.RequestClientCert
+	case "require":
+		base.ClientAuth = tls.RequireAnyClientCert
+	case "verify":
+		base.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require_verify":
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("tls: unsupported client auth %s", opt.ClientAuth)
+	}
+	if base.ClientAuth >= tls.VerifyClientCertIfGiven && opt.ClientCAFile == "" {
+		return nil, errors.New("tls: clientCAFile is required to verify client certificates")
+	}
+
+	r := &certReloader{opt: opt, base: base}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:         base.MinVersion,
+		GetConfigForClient: r.getConfigForClient,
+	}, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.opt.CertFile, r.opt.KeyFile}
+	if r.opt.ClientCAFile != "" {
+		files = append(files, r.opt.ClientCAFile)
+	}
+	return files
+}
+
+func (r *certReloader) load() error {
+	var modTimes []time.Time
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		modTimes = append(modTimes, fi.ModTime())
+	}
+	cert, err := tls.LoadX509KeyPair(r.opt.CertFile, r.opt.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load certificate %w", err)
+	}
+	conf := r.base.Clone()
+	conf.Certificates = []tls.Certificate{cert}
+	if r.opt.ClientCAFile != "" {
+		b, err := os.ReadFile(r.opt.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("tls: read client ca %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return errors.New("tls: no certificate found in client ca file")
+		}
+		conf.ClientCAs = pool
+	}
+	conf.NextProtos = []string{"h2", "http/1.1"}
+	r.mu.Lock()
+	r.conf = conf
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// changed 证书文件是否有变化 证书轮换时文件可能短暂不存在 此时继续使用旧证书
+func (r *certReloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) < certCheckInterval {
+		return false
+	}
+	r.checkedAt = time.Now()
+	for i, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return false
+		}
+		if !fi.ModTime().Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	if r.changed() {
+		if err := r.load(); err != nil {
+			slog.Error("tls: reload certificate failed", slog.String("err", err.Error()))
+		} else {
+			slog.Info("tls: certificate reloaded", slog.String("cert", r.opt.CertFile))
+		}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.conf, nil
+}
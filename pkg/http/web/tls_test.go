@@ -0,0 +1,241 @@
+package web
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "igo test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发证书 返回pem格式的证书和私钥
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+}
+
+func writeFile(t *testing.T, name string, b []byte, mod time.Time) {
+	t.Helper()
+	if err := os.WriteFile(name, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSOption(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	cert, key := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	opt := TLSOption{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	writeFile(t, opt.CertFile, cert, time.Now())
+	writeFile(t, opt.KeyFile, key, time.Now())
+
+	invalid := []func(o *TLSOption){
+		func(o *TLSOption) { o.MinVersion = "1.1" },
+		func(o *TLSOption) { o.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} },
+		func(o *TLSOption) { o.ClientAuth = "always" },
+		// 验证客户端证书必须设置CA
+		func(o *TLSOption) { o.ClientAuth = "verify" },
+		func(o *TLSOption) { o.CertFile = filepath.Join(dir, "missing.pem") },
+	}
+	for i, f := range invalid {
+		o := opt
+		f(&o)
+		if _, err := newTLSConfig(o); err == nil {
+			t.Errorf("case %d: expect error for %+v", i, o)
+		}
+	}
+
+	o := opt
+	o.MinVersion = "1.3"
+	conf, err := newTLSConfig(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := conf.GetConfigForClient(nil)
+	if c.MinVersion != tls.VersionTLS13 || len(c.Certificates) != 1 || c.ClientAuth != tls.NoClientCert {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	opt := TLSOption{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	old := time.Now().Add(-time.Minute)
+	cert, key := ca.issue(t, "v1", x509.ExtKeyUsageServerAuth)
+	writeFile(t, opt.CertFile, cert, old)
+	writeFile(t, opt.KeyFile, key, old)
+
+	r := &certReloader{opt: opt, base: &tls.Config{}}
+	if err := r.load(); err != nil {
+		t.Fatal(err)
+	}
+	subject := func() string {
+		c, err := r.getConfigForClient(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(c.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	cert, key = ca.issue(t, "v2", x509.ExtKeyUsageServerAuth)
+	writeFile(t, opt.CertFile, cert, time.Now())
+	writeFile(t, opt.KeyFile, key, time.Now())
+	// 检查间隔内不重新加载
+	if cn := subject(); cn != "v1" {
+		t.Fatalf("reloaded within check interval: %s", cn)
+	}
+	r.checkedAt = time.Time{}
+	if cn := subject(); cn != "v2" {
+		t.Fatalf("expect reloaded certificate, got %s", cn)
+	}
+
+	// 轮换过程中文件不完整时继续使用旧证书
+	writeFile(t, opt.KeyFile, []byte("broken"), time.Now().Add(time.Minute))
+	r.checkedAt = time.Time{}
+	if cn := subject(); cn != "v2" {
+		t.Fatalf("expect previous certificate kept, got %s", cn)
+	}
+	os.Remove(opt.CertFile)
+	r.checkedAt = time.Time{}
+	if cn := subject(); cn != "v2" {
+		t.Fatalf("expect previous certificate kept, got %s", cn)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	opt := TLSOption{
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	cert, key := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	writeFile(t, opt.CertFile, cert, time.Now())
+	writeFile(t, opt.KeyFile, key, time.Now())
+	writeFile(t, opt.ClientCAFile, ca.pem, time.Now())
+
+	conf, err := newTLSConfig(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(WithPProf(false))
+	srv.Router().Get("/whoami", func(ctx context.Context, in *Empty) (string, error) {
+		cert, ok := ClientCertificate(ctx)
+		if !ok {
+			return "", errors.New("no client certificate")
+		}
+		return cert.Subject.CommonName, nil
+	})
+	ts := httptest.NewUnstartedServer(srv.GinEngine())
+	ts.TLS = conf
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	get := func(certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		resp, err := client.Get(ts.URL + "/whoami")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b), nil
+	}
+
+	clientCert, clientKey := ca.issue(t, "svc-order", x509.ExtKeyUsageClientAuth)
+	pair, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := get(pair); err != nil || body != `"svc-order"` {
+		t.Fatalf("expect client certificate accepted: %s %v", body, err)
+	}
+	if _, err := get(); err == nil {
+		t.Fatal("expect handshake failure without client certificate")
+	}
+	// 其他CA签发的证书
+	otherCert, otherKey := newTestCA(t).issue(t, "intruder", x509.ExtKeyUsageClientAuth)
+	other, _ := tls.X509KeyPair(otherCert, otherKey)
+	if _, err := get(other); err == nil {
+		t.Fatal("expect certificate from unknown ca rejected")
+	}
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VersionOption 除了路径以外指定版本的方式 请求路径中没有版本时生效
+//
+//	X-API-Version: v2                            -> /v2/user
+//	Accept: application/vnd.igo.v2+json          -> /v2/user
+//	Accept: application/json; version=v2         -> /v2/user
+type VersionOption struct {
+	// Header 指定版本的请求头 如 X-API-Version 为空不启用
+	Header string
+	// Accept 通过Accept的媒体类型指定版本
+	Accept bool
+}
+
+func (v VersionOption) enabled() bool {
+	return v.Header != "" || v.Accept
+}
+
+// WithVersioning 通过请求头或Accept访问Router.Version注册的路由
+func WithVersioning(v VersionOption) Option {
+	return func(o *option) {
+		o.versioning = v
+	}
+}
+
+// apiVersion Router.Version注册的版本
+type apiVersion struct {
+	name string
+	// 版本分组的完整路径 如 /api/v2
+	path string
+	// 版本之前的路径 如 /api
+	base string
+}
+
+// addVersion 注册版本 相同的版本名只记录一次
+func (o *option) addVersion(name, path string) {
+	for _, v := range o.versions {
+		if v.name == name {
+			return
+		}
+	}
+	o.versions = append(o.versions, apiVersion{
+		name: name,
+		path: path,
+		base: strings.TrimSuffix(path, "/"+name),
+	})
+}
+
+// findVersion 版本号可以省略v 如 2 -> v2
+func (o *option) findVersion(name string) (apiVersion, bool) {
+	name = strings.TrimSpace(name)
+	for _, v := range o.versions {
+		if v.name == name || v.name == "v"+name {
+			return v, true
+		}
+	}
+	return apiVersion{}, false
+}
+
+// requestVersion 请求头中指定的版本 请求头优先于Accept
+func (o *option) requestVersion(r *http.Request) (apiVersion, bool) {
+	if h := o.versioning.Header; h != "" {
+		if s := r.Header.Get(h); s != "" {
+			return o.findVersion(s)
+		}
+	}
+	if o.versioning.Accept {
+		for _, accept := range r.Header.Values("Accept") {
+			for _, part := range strings.Split(accept, ",") {
+				if s := acceptVersion(part); s != "" {
+					return o.findVersion(s)
+				}
+			}
+		}
+	}
+	return apiVersion{}, false
+}
+
+// acceptVersion 媒体类型中的版本 version参数或者vnd子类型的最后一段
+func acceptVersion(s string) string {
+	mediaType, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return ""
+	}
+	if v := params["version"]; v != "" {
+		return v
+	}
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	if !strings.HasPrefix(subtype, "vnd.") {
+		return ""
+	}
+	subtype, _, _ = strings.Cut(subtype, "+")
+	if i := strings.LastIndexByte(subtype, '.'); i > 0 {
+		return subtype[i+1:]
+	}
+	return ""
+}
+
+// versionHandler 路由匹配之前把版本加到路径中 路径中已有版本时不处理
+func versionHandler(o *option, next http.Handler) http.Handler {
+	var vary []string
+	if o.versioning.Header != "" {
+		vary = append(vary, o.versioning.Header)
+	}
+	if o.versioning.Accept {
+		vary = append(vary, "Accept")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 响应因版本而不同 需要告知缓存
+		for _, h := range vary {
+			w.Header().Add("Vary", h)
+		}
+		if v, ok := o.requestVersion(r); ok && !o.hasVersionPrefix(r.URL.Path) &&
+			(v.base == "" || strings.HasPrefix(r.URL.Path, v.base+"/")) {
+			r2 := new(http.Request)
+			*r2 = *r
+			u := *r.URL
+			u.Path = v.path + strings.TrimPrefix(r.URL.Path, v.base)
+			u.RawPath = ""
+			r2.URL = &u
+			r = r2
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (o *option) hasVersionPrefix(path string) bool {
+	for _, v := range o.versions {
+		if path == v.path || strings.HasPrefix(path, v.path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// deprecationHeaders 已废弃的路由在响应中添加 Deprecation 和 Sunset
+func deprecationHeaders(c *gin.Context, info *routeInfo) {
+	if info == nil {
+		return
+	}
+	if at, ok := info.deprecation(); ok {
+		v := "true"
+		if !at.IsZero() {
+			// RFC 9745 结构化字段的日期
+			v = "@" + strconv.FormatInt(at.Unix(), 10)
+		}
+		c.Header("Deprecation", v)
+	}
+	if t := info.sunsetValue(); !t.IsZero() {
+		c.Header("Sunset", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// deprecation 路由或任一上级分组被声明为废弃 返回最近声明的废弃时间
+func (r *routeInfo) deprecation() (time.Time, bool) {
+	for p := r; p != nil; p = p.parent {
+		if p.deprecated != nil {
+			return *p.deprecated, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// sunsetValue 路由下线时间 未声明时使用上级分组的
+func (r *routeInfo) sunsetValue() time.Time {
+	for p := r; p != nil; p = p.parent {
+		if !p.sunset.IsZero() {
+			return p.sunset
+		}
+	}
+	return time.Time{}
+}
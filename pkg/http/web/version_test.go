@@ -0,0 +1,47 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+	"github.com/parkingwang/igo/pkg/http/web/webtest"
+)
+
+func TestVersion(t *testing.T) {
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := webtest.New(t, func(r web.Router) {
+		v1 := r.Version("v1")
+		v1.Deprecated().Sunset(sunset)
+		v1.Group("/user").Get("/:id", func(ctx context.Context, in *testUserRequest) (*testUser, error) {
+			return &testUser{ID: in.ID, Name: "v1"}, nil
+		})
+		r.Version("v2").Get("/user/:id", func(ctx context.Context, in *testUserRequest) (*testUser, error) {
+			return &testUser{ID: in.ID, Name: "v2"}, nil
+		})
+	}, web.WithVersioning(web.VersionOption{Header: "X-API-Version", Accept: true}))
+
+	s.Get("/v1/user/1").ExpectStatus(http.StatusOK).
+		ExpectBodyContains(`"v1"`).
+		ExpectHeader("Deprecation", "true").
+		ExpectHeader("Sunset", "Fri, 01 Jan 2027 00:00:00 GMT")
+	s.Get("/user/1").WithHeader("X-API-Version", "2").ExpectStatus(http.StatusOK).ExpectBodyContains(`"v2"`)
+	s.Get("/user/1").WithHeader("Accept", "application/vnd.igo.v1+json").ExpectStatus(http.StatusOK).ExpectBodyContains(`"v1"`)
+	s.Get("/user/1").WithHeader("Accept", "application/json; version=v2").ExpectStatus(http.StatusOK).ExpectBodyContains(`"v2"`)
+	// 路径中的版本优先
+	s.Get("/v2/user/1").WithHeader("X-API-Version", "v1").ExpectStatus(http.StatusOK).ExpectBodyContains(`"v2"`)
+
+	spec, err := s.Server().OpenAPI("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Paths) != 1 || spec.Info.Version != "v1" {
+		t.Fatalf("unexpected v1 doc %+v", spec.Paths)
+	}
+	if op := spec.Paths["/v1/user/{id}"]["get"].(oas.Request); !op.Deprecated {
+		t.Fatal("v1 route should be deprecated")
+	}
+}
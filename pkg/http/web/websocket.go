@@ -0,0 +1,249 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WSHandler websocket处理方法
+// 方法返回后连接会被关闭 返回error时以1011状态码关闭
+type WSHandler func(ctx context.Context, conn *WSConn) error
+
+// WSOption websocket配置
+type WSOption struct {
+	// 发送ping的间隔 必须小于PongWait
+	PingInterval time.Duration
+	// 等待pong的超时时间 超时未收到任何消息则断开
+	PongWait time.Duration
+	// 写入超时
+	WriteWait time.Duration
+	// 单条消息最大字节数
+	ReadLimit int64
+	// 跨域检查 默认只允许同源
+	CheckOrigin func(r *http.Request) bool
+}
+
+func defaultWSOption() WSOption {
+	return WSOption{
+		PingInterval: time.Second * 30,
+		PongWait:     time.Second * 60,
+		WriteWait:    time.Second * 10,
+		ReadLimit:    1 << 20,
+	}
+}
+
+var (
+	meter = otel.Meter("github.com/parkingwang/igo/pkg/http/web")
+
+	wsActiveConns, _ = meter.Int64UpDownCounter(
+		"http.server.websocket.active_connections",
+		metric.WithDescription("当前websocket连接数"),
+	)
+	wsTotalConns, _ = meter.Int64Counter(
+		"http.server.websocket.connections",
+		metric.WithDescription("websocket累计连接数"),
+	)
+)
+
+// WSConn websocket连接 读写消息均为json格式
+// WriteJSON 可以并发调用 ReadJSON 只能在一个goroutine里调用
+type WSConn struct {
+	conn   *websocket.Conn
+	opt    WSOption
+	wmu    sync.Mutex
+	closed atomic.Bool
+	done   chan struct{}
+
+	mu      sync.Mutex
+	onClose []func(*WSConn)
+	in, out atomic.Int64
+}
+
+// ReadJSON 读取一条消息并解析到v
+func (c *WSConn) ReadJSON(v any) error {
+	if err := c.conn.ReadJSON(v); err != nil {
+		return err
+	}
+	c.in.Add(1)
+	return nil
+}
+
+// WriteJSON 写入一条json消息
+func (c *WSConn) WriteJSON(v any) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.opt.WriteWait))
+	if err := c.conn.WriteJSON(v); err != nil {
+		return err
+	}
+	c.out.Add(1)
+	return nil
+}
+
+// Raw 返回原始连接 用于收发二进制等非json消息
+// 注意自行处理并发写入
+func (c *WSConn) Raw() *websocket.Conn {
+	return c.conn
+}
+
+// Done 连接关闭后返回
+func (c *WSConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close 发送关闭帧并断开连接 可重复调用
+func (c *WSConn) Close(code int, reason string) error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	close(c.done)
+	c.wmu.Lock()
+	c.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(c.opt.WriteWait),
+	)
+	c.wmu.Unlock()
+	c.mu.Lock()
+	hooks := c.onClose
+	c.mu.Unlock()
+	for _, f := range hooks {
+		f(c)
+	}
+	return c.conn.Close()
+}
+
+// addCloseHook 添加连接关闭时的回调 连接已关闭时返回false
+func (c *WSConn) addCloseHook(f func(*WSConn)) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed.Load() {
+		return false
+	}
+	c.onClose = append(c.onClose, f)
+	return true
+}
+
+func (c *WSConn) keepalive() {
+	ticker := time.NewTicker(c.opt.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(
+				websocket.PingMessage, nil, time.Now().Add(c.opt.WriteWait),
+			); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsConnSet 记录所有活跃的连接 服务关闭时统一断开
+type wsConnSet struct {
+	m sync.Map
+}
+
+func (s *wsConnSet) closeAll() {
+	s.m.Range(func(key, _ any) bool {
+		key.(*WSConn).Close(websocket.CloseGoingAway, "server shutdown")
+		return true
+	})
+}
+
+func (s *route) WebSocket(path string, handler WSHandler, middleware ...gin.HandlerFunc) Commenter {
+	info := s.opt.routes.addRoute(s.basepath, path, handler, http.MethodGet)
+	if info != nil {
+		info.websocket = true
+		info.version = s.version
+	}
+	hs := append(append([]gin.HandlerFunc{}, middleware...), serveWebSocket(s.opt, info, s.basepath+path, handler))
+	s.r.GET(path, hs...)
+	return &route{info: info}
+}
+
+func serveWebSocket(opt *option, info *routeInfo, path string, handler WSHandler) gin.HandlerFunc {
+	tracer := otel.GetTracerProvider().Tracer("github.com/parkingwang/igo/pkg/http/web")
+	upgrader := websocket.Upgrader{
+		CheckOrigin: opt.ws.CheckOrigin,
+	}
+	attrs := metric.WithAttributes(attribute.String("http.route", path))
+	return func(c *gin.Context) {
+		deprecationHeaders(c, info)
+		if err := guard(c, opt, info); err != nil {
+			warpRender(opt, c, nil, err)
+			return
+		}
+		// 仅用于访问日志 升级失败时会被覆盖
+		c.Status(http.StatusSwitchingProtocols)
+		raw, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		ctx, span := tracer.Start(c.Request.Context(), "websocket "+path,
+			trace.WithSpanKind(trace.SpanKindServer),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		conn := &WSConn{
+			conn: raw,
+			opt:  opt.ws,
+			done: make(chan struct{}),
+		}
+		// 劫持后的连接仍保留了server的WriteTimeout 由keepalive和WriteWait负责
+		raw.NetConn().SetWriteDeadline(time.Time{})
+		raw.SetReadLimit(opt.ws.ReadLimit)
+		raw.SetReadDeadline(time.Now().Add(opt.ws.PongWait))
+		raw.SetPongHandler(func(string) error {
+			return raw.SetReadDeadline(time.Now().Add(opt.ws.PongWait))
+		})
+		opt.wsconns.m.Store(conn, struct{}{})
+		wsActiveConns.Add(ctx, 1, attrs)
+		wsTotalConns.Add(ctx, 1, attrs)
+		defer func() {
+			opt.wsconns.m.Delete(conn)
+			wsActiveConns.Add(ctx, -1, attrs)
+			span.SetAttributes(
+				attribute.Int64("websocket.messages.received", conn.in.Load()),
+				attribute.Int64("websocket.messages.sent", conn.out.Load()),
+			)
+		}()
+		go conn.keepalive()
+
+		err = handler(c, conn)
+		switch {
+		case conn.closed.Load():
+			// 服务关闭时已经断开
+		case err == nil:
+			conn.Close(websocket.CloseNormalClosure, "")
+		case websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway),
+			errors.Is(err, websocket.ErrCloseSent):
+			// 客户端或服务端主动关闭
+			conn.Close(websocket.CloseNormalClosure, "")
+		default:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			slog.LogAttrs(ctx, slog.LevelError, "gin.websocket", slog.String("err", err.Error()))
+			// 关闭原因最多123字节 不直接返回错误信息
+			conn.Close(websocket.CloseInternalServerErr, http.StatusText(http.StatusInternalServerError))
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/parkingwang/igo/pkg/http/web"
+)
+
+type wsMessage struct {
+	Room string `json:"room"`
+	Text string `json:"text"`
+}
+
+// wsServer 启动一个真实的http服务 websocket需要劫持连接
+func wsServer(t *testing.T, setup func(r web.Router), opts ...web.Option) (*web.Server, string) {
+	t.Helper()
+	srv := web.New(append([]web.Option{web.WithPProf(false)}, opts...)...)
+	setup(srv.Router())
+	ts := httptest.NewServer(srv.GinEngine())
+	t.Cleanup(ts.Close)
+	return srv, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func wsDial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWebSocketHub(t *testing.T) {
+	hub := web.NewHub()
+	_, url := wsServer(t, func(r web.Router) {
+		r.WebSocket("/chat", func(ctx context.Context, conn *web.WSConn) error {
+			for {
+				var msg wsMessage
+				if err := conn.ReadJSON(&msg); err != nil {
+					return err
+				}
+				if msg.Text == "" {
+					hub.Join(msg.Room, conn)
+					if err := conn.WriteJSON(wsMessage{Room: msg.Room, Text: "joined"}); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := hub.Broadcast(msg.Room, msg); err != nil {
+					return err
+				}
+			}
+		})
+	})
+
+	join := func(room string) *websocket.Conn {
+		conn := wsDial(t, url+"/chat")
+		var ack wsMessage
+		if err := conn.WriteJSON(wsMessage{Room: room}); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.ReadJSON(&ack); err != nil || ack.Text != "joined" {
+			t.Fatalf("join %s: %v %v", room, ack, err)
+		}
+		return conn
+	}
+	a, b, other := join("r1"), join("r1"), join("r2")
+	if n := hub.Count("r1"); n != 2 {
+		t.Fatalf("expect 2 conns in r1, got %d", n)
+	}
+
+	if err := a.WriteJSON(wsMessage{Room: "r1", Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*websocket.Conn{a, b} {
+		var msg wsMessage
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		if err := c.ReadJSON(&msg); err != nil || msg.Text != "hi" {
+			t.Fatalf("broadcast: %v %v", msg, err)
+		}
+	}
+	// 其他分组收不到
+	other.SetReadDeadline(time.Now().Add(time.Millisecond * 50))
+	var msg wsMessage
+	if err := other.ReadJSON(&msg); err == nil {
+		t.Fatalf("r2 should not receive %v", msg)
+	}
+
+	// 连接断开后自动离开分组
+	b.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	b.Close()
+	deadline := time.Now().Add(time.Second)
+	for hub.Count("r1") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("closed conn not removed from hub: %d", hub.Count("r1"))
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	done := make(chan error, 1)
+	_, url := wsServer(t, func(r web.Router) {
+		r.WebSocket("/ws", func(ctx context.Context, conn *web.WSConn) error {
+			var v any
+			err := conn.ReadJSON(&v)
+			done <- err
+			return err
+		})
+	}, web.WithWebSocket(web.WSOption{
+		PingInterval: time.Millisecond * 20,
+		PongWait:     time.Millisecond * 100,
+	}))
+
+	// 客户端回复pong时连接保持
+	var pings atomic.Int32
+	conn := wsDial(t, url+"/ws")
+	conn.SetPingHandler(func(data string) error {
+		pings.Add(1)
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("conn closed while answering pings: %v", err)
+	case <-time.After(time.Millisecond * 250):
+	}
+	if pings.Load() < 3 {
+		t.Fatalf("expect periodic pings, got %d", pings.Load())
+	}
+	conn.Close()
+	<-done
+
+	// 不回复pong时超过PongWait断开
+	silent := wsDial(t, url+"/ws")
+	silent.SetPingHandler(func(string) error { return nil })
+	go func() {
+		for {
+			if _, _, err := silent.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expect read timeout")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("conn without pong not closed")
+	}
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	srv, url := wsServer(t, func(r web.Router) {
+		r.WebSocket("/ws", func(ctx context.Context, conn *web.WSConn) error {
+			<-conn.Done()
+			return nil
+		})
+	})
+	conn := wsDial(t, url+"/ws")
+	// 等待连接注册完成
+	time.Sleep(time.Millisecond * 20)
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expect going away on shutdown, got %v", err)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	_, url := wsServer(t, func(r web.Router) {
+		r.WebSocket("/ws", func(ctx context.Context, conn *web.WSConn) error {
+			return nil
+		})
+	})
+	header := http.Header{"Origin": {"http://evil.example"}}
+	_, resp, err := websocket.DefaultDialer.Dial(url+"/ws", header)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expect cross origin rejected: %v", err)
+	}
+}
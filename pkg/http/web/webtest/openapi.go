@@ -0,0 +1,192 @@
+package webtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+)
+
+// validateResponse 按文档验证响应 未声明结构的响应(如错误响应)不验证
+func validateResponse(spec *oas.Spec, method, path string, w *httptest.ResponseRecorder) error {
+	op, ok := findOperation(spec, method, path)
+	if !ok {
+		return nil
+	}
+	body, ok := op.Responses[strconv.Itoa(w.Code)]
+	if !ok {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	content, ok := matchContent(body.Content, mediaType)
+	if len(body.Content) > 0 && !ok {
+		return fmt.Errorf("content type %q not in %v", mediaType, keys(body.Content))
+	}
+	schema, ok := content["schema"]
+	if !ok || !strings.HasSuffix(mediaType, "json") {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(w.Body.Bytes()))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	return validate("$", schema, v)
+}
+
+// matchContent 文档中的媒体类型支持 */* 和 image/* 形式的范围
+func matchContent(content map[string]map[string]oas.Schema, mediaType string) (map[string]oas.Schema, bool) {
+	if v, ok := content[mediaType]; ok {
+		return v, true
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, k := range []string{major + "/*", "*/*"} {
+		if v, ok := content[k]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// findOperation 按路径模板匹配 如 /user/{id} 优先匹配固定部分最多的
+func findOperation(spec *oas.Spec, method, path string) (oas.Request, bool) {
+	var (
+		found oas.Request
+		best  = -1
+	)
+	method = strings.ToLower(method)
+	segs := strings.Split(path, "/")
+	for tpl, ops := range spec.Paths {
+		parts := strings.Split(tpl, "/")
+		if len(parts) != len(segs) {
+			continue
+		}
+		score := 0
+		for i, p := range parts {
+			if p == segs[i] {
+				score++
+			} else if !(strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}")) {
+				score = -1
+				break
+			}
+		}
+		if op, ok := ops[method].(oas.Request); ok && score > best {
+			found, best = op, score
+		}
+	}
+	return found, best >= 0
+}
+
+// validate 生成的文档不区分nullable null总是合法
+func validate(path string, s oas.Schema, v any) error {
+	if v == nil || s.Type == "" {
+		return nil
+	}
+	switch s.Type {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: invalid date-time %q", path, str)
+			}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		if _, err := n.Int64(); err != nil {
+			return fmt.Errorf("%s: expect integer, got %s", path, n)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return typeError(path, s.Type, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(path, s.Type, v)
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := validate(fmt.Sprintf("%s[%d]", path, i), *s.Items, item); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		// interface{}生成的是没有属性的object 可以是任意值
+		if len(s.Properties) == 0 && s.AdditionalProperties == nil {
+			return nil
+		}
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		// map的值的结构在example中
+		if s.AdditionalProperties != nil && *s.AdditionalProperties {
+			if elem, ok := s.Properties["example"]; ok {
+				for k, item := range obj {
+					if err := validate(path+"."+k, elem, item); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+		for _, k := range s.Required {
+			if _, ok := obj[k]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, k)
+			}
+		}
+		for k, item := range obj {
+			p, ok := s.Properties[k]
+			if !ok {
+				return fmt.Errorf("%s: property %q not in schema", path, k)
+			}
+			if err := validate(path+"."+k, p, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func typeError(path, expect string, v any) error {
+	var got string
+	switch v.(type) {
+	case string:
+		got = "string"
+	case json.Number:
+		got = "number"
+	case bool:
+		got = "boolean"
+	case []any:
+		got = "array"
+	case map[string]any:
+		got = "object"
+	}
+	return fmt.Errorf("%s: expect %s, got %s", path, expect, got)
+}
+
+func keys[V any](m map[string]V) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	return list
+}
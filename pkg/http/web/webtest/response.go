@@ -0,0 +1,83 @@
+package webtest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Response 测试响应 断言失败时测试失败但继续执行
+type Response struct {
+	*httptest.ResponseRecorder
+	t       testing.TB
+	traceID oteltrace.TraceID
+}
+
+// TraceID 请求的traceid 服务端的span和日志使用同一个
+func (r *Response) TraceID() string {
+	return r.traceID.String()
+}
+
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Errorf("webtest: expect status %d, got %d: %s", code, r.Code, r.Body.String())
+	}
+	return r
+}
+
+func (r *Response) ExpectHeader(key, value string) *Response {
+	r.t.Helper()
+	if v := r.Header().Get(key); v != value {
+		r.t.Errorf("webtest: expect header %s=%q, got %q", key, value, v)
+	}
+	return r
+}
+
+// ExpectBodyContains 响应体包含指定内容
+func (r *Response) ExpectBodyContains(s string) *Response {
+	r.t.Helper()
+	if !strings.Contains(r.Body.String(), s) {
+		r.t.Errorf("webtest: expect body contains %q, got %s", s, r.Body.String())
+	}
+	return r
+}
+
+// ExpectTraceID 服务端的span属于请求的trace
+// 响应体中有traceid字段时(如错误响应) 同样需要一致
+func (r *Response) ExpectTraceID() *Response {
+	r.t.Helper()
+	if span := r.serverSpan(); span == nil {
+		r.t.Errorf("webtest: no server span in trace %s", r.traceID)
+	}
+	var body struct {
+		TraceID string `json:"traceid"`
+	}
+	if json.Unmarshal(r.Body.Bytes(), &body) == nil && body.TraceID != "" && body.TraceID != r.traceID.String() {
+		r.t.Errorf("webtest: expect traceid %s in body, got %s", r.traceID, body.TraceID)
+	}
+	return r
+}
+
+// DecodeJSON 解析json响应体
+func (r *Response) DecodeJSON(out any) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), out); err != nil {
+		r.t.Errorf("webtest: decode json %v: %s", err, r.Body.String())
+	}
+	return r
+}
+
+// serverSpan 服务端处理请求的span
+func (r *Response) serverSpan() trace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == r.traceID && span.SpanKind() == oteltrace.SpanKindServer {
+			return span
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package webtest 不监听端口测试web.Server的路由
+//
+//	s := webtest.New(t, func(r web.Router) {
+//		r.Get("/user/:id", GetUser)
+//	}).ValidateOpenAPI()
+//
+//	var out User
+//	s.Get("/user/1").WithHeader("Authorization", "Bearer x").
+//		ExpectStatus(200).ExpectTraceID().DecodeJSON(&out)
+package webtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+	"github.com/parkingwang/igo/pkg/http/web/oas"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	traceOnce sync.Once
+	recorder  = tracetest.NewSpanRecorder()
+	tracer    trace.Tracer
+)
+
+// setupTrace 使用记录span的TracerProvider 用于验证traceid
+// 已经设置了propagator时保持不变
+func setupTrace() {
+	traceOnce.Do(func() {
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		otel.SetTracerProvider(tp)
+		if len(otel.GetTextMapPropagator().Fields()) == 0 {
+			otel.SetTextMapPropagator(propagation.TraceContext{})
+		}
+		tracer = tp.Tracer("github.com/parkingwang/igo/pkg/http/web/webtest")
+	})
+}
+
+// Server 测试用的服务
+type Server struct {
+	t       testing.TB
+	srv     *web.Server
+	handler http.Handler
+	spec    *oas.Spec
+}
+
+// New 创建服务并注册路由 失败时终止测试
+func New(t testing.TB, setup func(r web.Router), opts ...web.Option) *Server {
+	t.Helper()
+	setupTrace()
+	srv := web.New(append([]web.Option{web.WithPProf(false)}, opts...)...)
+	if setup != nil {
+		setup(srv.Router())
+	}
+	h, err := srv.Handler()
+	if err != nil {
+		t.Fatalf("webtest: %v", err)
+	}
+	return &Server{t: t, srv: srv, handler: h}
+}
+
+// Server 返回原始的web.Server
+func (s *Server) Server() *web.Server {
+	return s.srv
+}
+
+// ValidateOpenAPI 按生成的文档验证所有成功响应的结构
+// 响应中有文档未声明的字段或者类型不一致时测试失败
+func (s *Server) ValidateOpenAPI() *Server {
+	s.t.Helper()
+	spec, err := s.srv.OpenAPI()
+	if err != nil {
+		s.t.Fatalf("webtest: build openapi %v", err)
+	}
+	s.spec = spec
+	return s
+}
+
+func (s *Server) Get(path string) *Request {
+	return s.Request(http.MethodGet, path)
+}
+
+func (s *Server) Post(path string) *Request {
+	return s.Request(http.MethodPost, path)
+}
+
+func (s *Server) Put(path string) *Request {
+	return s.Request(http.MethodPut, path)
+}
+
+func (s *Server) Patch(path string) *Request {
+	return s.Request(http.MethodPatch, path)
+}
+
+func (s *Server) Delete(path string) *Request {
+	return s.Request(http.MethodDelete, path)
+}
+
+// Request 创建请求 Expect时发送
+func (s *Server) Request(method, path string) *Request {
+	return &Request{
+		s:      s,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+}
+
+// Request 测试请求
+type Request struct {
+	s      *Server
+	method string
+	path   string
+	header http.Header
+	query  url.Values
+	body   io.Reader
+	ctx    context.Context
+}
+
+func (r *Request) WithHeader(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+func (r *Request) WithQuery(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// WithJSON json请求体
+func (r *Request) WithJSON(v any) *Request {
+	b, err := json.Marshal(v)
+	if err != nil {
+		r.s.t.Fatalf("webtest: encode json %v", err)
+	}
+	r.header.Set("Content-Type", "application/json")
+	r.body = bytes.NewReader(b)
+	return r
+}
+
+// WithForm application/x-www-form-urlencoded请求体
+func (r *Request) WithForm(v url.Values) *Request {
+	r.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.body = strings.NewReader(v.Encode())
+	return r
+}
+
+// WithBody 原始请求体
+func (r *Request) WithBody(contentType string, body io.Reader) *Request {
+	r.header.Set("Content-Type", contentType)
+	r.body = body
+	return r
+}
+
+// WithContext 请求的context 可以用于设置超时
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r.ctx = ctx
+	return r
+}
+
+// Expect 发送请求 返回响应用于断言
+// 每个请求都会带上新的trace 用于验证服务端的traceid
+func (r *Request) Expect() *Response {
+	r.s.t.Helper()
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := tracer.Start(ctx, "webtest "+r.method+" "+r.path, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	target := r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	req := httptest.NewRequestWithContext(ctx, r.method, target, r.body)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	w := httptest.NewRecorder()
+	r.s.handler.ServeHTTP(w, req)
+	res := &Response{
+		t:                r.s.t,
+		ResponseRecorder: w,
+		traceID:          span.SpanContext().TraceID(),
+	}
+	if r.s.spec != nil {
+		if err := validateResponse(r.s.spec, r.method, req.URL.Path, w); err != nil {
+			r.s.t.Errorf("webtest: %s %s response does not match openapi: %v", r.method, r.path, err)
+		}
+	}
+	return res
+}
+
+// ExpectStatus 发送请求并验证状态码
+func (r *Request) ExpectStatus(code int) *Response {
+	r.s.t.Helper()
+	return r.Expect().ExpectStatus(code)
+}
@@ -0,0 +1,76 @@
+package webtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/parkingwang/igo/pkg/http/code"
+	"github.com/parkingwang/igo/pkg/http/web"
+)
+
+type user struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// badUser Extra没有json tag 文档中不存在
+type badUser struct {
+	ID    string `json:"id"`
+	Extra string
+}
+
+type getUserRequest struct {
+	ID int64 `uri:"id"`
+}
+
+// recordTB 记录断言失败 用于验证失败的情况
+type recordTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func setup(r web.Router) {
+	r.Get("/user/:id", func(ctx context.Context, in *getUserRequest) (*user, error) {
+		if in.ID == 0 {
+			return nil, code.NewNotfoundError("user not found")
+		}
+		return &user{ID: in.ID, Name: "bob"}, nil
+	})
+	r.Get("/bad", func(ctx context.Context, in *web.Empty) (*badUser, error) {
+		return &badUser{ID: "1", Extra: "x"}, nil
+	})
+	r.Get("/download", func(ctx context.Context, in *web.Empty) (*web.FileResponse, error) {
+		return &web.FileResponse{Name: "a.txt", Content: strings.NewReader("hello")}, nil
+	})
+}
+
+func TestServer(t *testing.T) {
+	s := New(t, setup).ValidateOpenAPI()
+	var out user
+	s.Get("/user/1").WithHeader("X-Request-ID", "abc").
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("X-Request-ID", "abc").
+		ExpectTraceID().
+		DecodeJSON(&out)
+	if out.ID != 1 || out.Name != "bob" {
+		t.Fatalf("unexpected %+v", out)
+	}
+	s.Get("/user/0").ExpectStatus(http.StatusNotFound).ExpectTraceID().ExpectBodyContains("user not found")
+	// 文件响应在文档中为 */*
+	s.Get("/download").ExpectStatus(http.StatusOK).ExpectBodyContains("hello")
+}
+
+func TestValidateOpenAPI(t *testing.T) {
+	rt := &recordTB{TB: t}
+	New(rt, setup).ValidateOpenAPI().Get("/bad").ExpectStatus(http.StatusOK)
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], `property "Extra" not in schema`) {
+		t.Fatalf("expect openapi error, got %v", rt.errors)
+	}
+}
@@ -3,8 +3,11 @@ package amqp
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/parkingwang/igo/internal/panics"
+	"github.com/parkingwang/igo/pkg/requestid"
 	"github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
@@ -66,7 +69,7 @@ func (s *Consumer) do(ctx context.Context, c *amqp091.Connection, serr error) bo
 		}
 		go func(h MessageHandle) {
 			for msg := range msgs {
-				h(fromDelivery(msg), msg)
+				s.handle(name, h, msg)
 			}
 			// 以便其他handler也可以退出
 			cancel()
@@ -118,6 +121,21 @@ func (s *Consumer) Stop(ctx context.Context) error {
 	return nil
 }
 
+// handle handler发生panic时记录堆栈 拒绝消息(不重新入队 配置了死信队列时进入死信队列)
+// 并通过WithOnError通知 不影响后续消息的处理
+func (s *Consumer) handle(queue string, h MessageHandle, msg amqp091.Delivery) {
+	ctx := fromDelivery(msg)
+	defer func() {
+		if v := recover(); v != nil {
+			e := panics.New(v)
+			panics.Record(ctx, "amqp.panic", e, slog.String("queue", queue))
+			msg.Nack(false, false)
+			s.opt.err(e)
+		}
+	}()
+	h(ctx, msg)
+}
+
 func fromDelivery(d amqp091.Delivery) context.Context {
 	ctx := context.TODO()
 	tablemap := make(map[string]string)
@@ -126,5 +144,8 @@ func fromDelivery(d amqp091.Delivery) context.Context {
 			tablemap[k] = a
 		}
 	}
+	if id := tablemap[requestid.Header]; requestid.Valid(id) {
+		ctx = requestid.NewContext(ctx, id)
+	}
 	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(tablemap))
 }
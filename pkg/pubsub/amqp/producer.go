@@ -5,6 +5,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/parkingwang/igo/pkg/requestid"
 	"github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -89,6 +90,11 @@ func (p *Producer) PublishMsg(ctx context.Context, exchange, key string, msg amq
 	for k, v := range tablemap {
 		msg.Headers[k] = v
 	}
+	if id := requestid.FromContext(ctx); id != "" {
+		if _, ok := msg.Headers[requestid.Header]; !ok {
+			msg.Headers[requestid.Header] = id
+		}
+	}
 
 	if p.tryCnnection.Load() {
 		return amqp091.ErrClosed
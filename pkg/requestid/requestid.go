@@ -0,0 +1,52 @@
+// Package requestid 请求ID 在web/client/amqp/日志之间传递
+// 不依赖trace 在trace未采样或未启用时仍可用于定位请求
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header 传递请求ID的header
+const Header = "X-Request-ID"
+
+// 外部传入的请求ID的最大长度
+const maxLen = 128
+
+type ctxKey struct{}
+
+// New 生成新的请求ID 32位十六进制
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewContext 保存请求ID到context
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext 返回context中的请求ID 不存在返回空
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Valid 外部传入的请求ID是否可用 限制长度和字符 避免日志注入
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
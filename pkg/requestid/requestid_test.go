@@ -0,0 +1,23 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	id := New()
+	if len(id) != 32 || !Valid(id) || New() == id {
+		t.Fatalf("unexpected id %s", id)
+	}
+	ctx := NewContext(context.Background(), id)
+	if FromContext(ctx) != id || FromContext(context.Background()) != "" {
+		t.Fatal("context mismatch")
+	}
+	for _, v := range []string{"", "a b", "a\nb", strings.Repeat("a", 129)} {
+		if Valid(v) {
+			t.Fatalf("expect invalid %q", v)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package igo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/parkingwang/igo/internal/panics"
+)
+
+// ErrorReporter 错误上报 用于对接sentry等错误跟踪系统
+// web服务的panic和5xx错误 igo.Go中的panic 会自动上报
+type ErrorReporter interface {
+	Report(ctx context.Context, err error)
+}
+
+// PanicError recover捕获的panic 包含panic时的堆栈
+type PanicError = panics.Error
+
+var (
+	reportersMu sync.RWMutex
+	reporters   []ErrorReporter
+)
+
+// AddErrorReporter 注册错误上报
+func AddErrorReporter(r ...ErrorReporter) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	reporters = append(reporters, r...)
+}
+
+// ReportError 上报错误到所有注册的ErrorReporter
+func ReportError(ctx context.Context, err error) {
+	reportersMu.RLock()
+	defer reportersMu.RUnlock()
+	for _, r := range reporters {
+		r.Report(ctx, err)
+	}
+}
+
+// Go 启动goroutine panic时记录堆栈并上报 不会导致进程退出
+// 用于Servicer等启动的后台任务
+func Go(ctx context.Context, f func(context.Context)) {
+	go func() {
+		defer Recover(ctx)
+		f(ctx)
+	}()
+}
+
+// Recover 捕获panic 记录堆栈并上报 需要直接在defer中调用
+//
+//	defer igo.Recover(ctx)
+func Recover(ctx context.Context) {
+	if v := recover(); v != nil {
+		reportPanic(ctx, panics.New(v))
+	}
+}
+
+func reportPanic(ctx context.Context, e *PanicError) {
+	panics.Record(ctx, "panic recovered", e)
+	ReportError(ctx, e)
+}
+
+// safeHook Servicer的Start/Stop发生panic时转为错误
+func safeHook(f func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				e := panics.New(v)
+				reportPanic(ctx, e)
+				err = e
+			}
+		}()
+		return f(ctx)
+	}
+}
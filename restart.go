@@ -0,0 +1,185 @@
+//go:build !windows
+
+package igo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"log/slog"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+	"go.uber.org/fx"
+)
+
+// envReadyFD 新进程启动完成后通过此fd通知父进程
+const envReadyFD = "IGO_READY_FD"
+
+// listenerHandoff 平滑重启时可以传递监听的服务 如 *web.Server
+type listenerHandoff interface {
+	ListenerFiles() ([]string, []*os.File, error)
+	CommitHandoff()
+}
+
+// gracefulRestart 收到SIGHUP/SIGUSR2时启动新进程并传递监听
+// 新进程所有服务启动完成后 当前进程按正常流程停止 等待请求处理完成
+func gracefulRestart(timeout time.Duration) any {
+	return fx.Annotate(
+		func(srvs []Servicer, lc fx.Lifecycle, sd fx.Shutdowner) {
+			ch := make(chan os.Signal, 1)
+			lc.Append(fx.Hook{
+				// 在所有服务之后启动 此时已经可以处理请求
+				OnStart: func(context.Context) error {
+					if err := notifyReady(); err != nil {
+						slog.Warn("notify parent process failed", slog.String("err", err.Error()))
+					}
+					signal.Notify(ch, syscall.SIGHUP, syscall.SIGUSR2)
+					go func() {
+						for sig := range ch {
+							slog.Info("graceful restart", slog.String("signal", sig.String()))
+							pid, err := startChild(srvs, timeout)
+							if err != nil {
+								slog.Error("graceful restart failed", slog.String("err", err.Error()))
+								continue
+							}
+							slog.Info("new process ready", slog.Int("pid", pid))
+							sd.Shutdown()
+							return
+						}
+					}()
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					signal.Stop(ch)
+					return nil
+				},
+			})
+		},
+		fx.ParamTags(`group:"services"`),
+	)
+}
+
+// startChild 启动新进程并传递监听 等待新进程就绪
+func startChild(srvs []Servicer, timeout time.Duration) (int, error) {
+	var (
+		files    []*os.File
+		handoffs []listenerHandoff
+		inherit  = make(map[string][]int)
+	)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, s := range srvs {
+		h, ok := s.(listenerHandoff)
+		if !ok {
+			continue
+		}
+		specs, fs, err := h.ListenerFiles()
+		if err != nil {
+			return 0, err
+		}
+		handoffs = append(handoffs, h)
+		for i, spec := range specs {
+			// ExtraFiles从fd 3开始
+			inherit[spec] = append(inherit[spec], 3+len(files))
+			files = append(files, fs[i])
+		}
+	}
+	listeners, err := json.Marshal(inherit)
+	if err != nil {
+		return 0, err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		w.Close()
+		return 0, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(childEnv(),
+		web.EnvInheritListeners+"="+string(listeners),
+		envReadyFD+"="+strconv.Itoa(3+len(files)),
+	)
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		if _, err := r.Read(b); err != nil {
+			ready <- errors.New("new process exited before ready")
+			return
+		}
+		ready <- nil
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(timeout):
+		err = fmt.Errorf("new process not ready after %s", timeout)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return 0, err
+	}
+	for _, h := range handoffs {
+		h.CommitHandoff()
+	}
+	// 新进程由init接管 避免父进程退出前产生僵尸进程
+	go cmd.Wait()
+	return cmd.Process.Pid, nil
+}
+
+// childEnv 去掉只对当前进程有效的环境变量
+func childEnv() []string {
+	var env []string
+	for _, v := range os.Environ() {
+		if strings.HasPrefix(v, "LISTEN_") || strings.HasPrefix(v, envReadyFD+"=") ||
+			strings.HasPrefix(v, web.EnvInheritListeners+"=") {
+			continue
+		}
+		env = append(env, v)
+	}
+	return env
+}
+
+// notifyReady 平滑重启启动的新进程通知父进程已经就绪
+func notifyReady() error {
+	v := os.Getenv(envReadyFD)
+	if v == "" {
+		return nil
+	}
+	os.Unsetenv(envReadyFD)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
@@ -0,0 +1,107 @@
+//go:build !windows
+
+package igo
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/parkingwang/igo/pkg/http/web"
+)
+
+// 平滑重启时启动的新进程就是测试程序本身
+// 通过继承的监听判断当前是新进程
+func TestMain(m *testing.M) {
+	if os.Getenv(web.EnvInheritListeners) != "" {
+		runRestartChild()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runRestartChild 使用继承的监听启动服务 通知父进程后等待被结束
+func runRestartChild() {
+	srv := newPidServer(os.Getenv("IGO_TEST_LISTEN"))
+	if err := srv.Start(context.Background()); err != nil {
+		os.Exit(2)
+	}
+	if err := notifyReady(); err != nil {
+		os.Exit(3)
+	}
+	time.Sleep(time.Second * 10)
+	os.Exit(0)
+}
+
+func newPidServer(spec string) *web.Server {
+	srv := web.New(web.WithPProf(false), web.WithListen(spec))
+	srv.Router().Get("/pid", func(ctx context.Context, in *web.Empty) (int, error) {
+		return os.Getpid(), nil
+	})
+	return srv
+}
+
+func TestGracefulRestart(t *testing.T) {
+	// 先占用一个端口 交给服务监听
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	t.Setenv("IGO_TEST_LISTEN", addr)
+
+	srv := newPidServer(addr)
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pid := func() int {
+		resp, err := http.Get("http://" + addr + "/pid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		n, _ := strconv.Atoi(string(b))
+		return n
+	}
+	if p := pid(); p != os.Getpid() {
+		t.Fatalf("expect served by current process, got %d", p)
+	}
+
+	child, err := startChild([]Servicer{srv}, time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Kill(child, syscall.SIGKILL)
+	// 旧进程停止后 同一个地址由新进程处理
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if p := pid(); p != child {
+		t.Fatalf("expect served by new process %d, got %d", child, p)
+	}
+}
+
+func TestChildEnv(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv(envReadyFD, "5")
+	t.Setenv(web.EnvInheritListeners, "{}")
+	t.Setenv("IGO_TEST_KEEP", "1")
+	env := strings.Join(childEnv(), "\n")
+	for _, k := range []string{"LISTEN_FDS=", envReadyFD + "=", web.EnvInheritListeners + "="} {
+		if strings.Contains(env, k) {
+			t.Errorf("%s should not be passed to new process", k)
+		}
+	}
+	if !strings.Contains(env, "IGO_TEST_KEEP=1") {
+		t.Error("other env should be kept")
+	}
+}
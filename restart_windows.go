@@ -0,0 +1,14 @@
+package igo
+
+import (
+	"time"
+
+	"log/slog"
+)
+
+// gracefulRestart windows不支持传递监听
+func gracefulRestart(time.Duration) any {
+	return func() {
+		slog.Warn("graceful restart is not supported on windows")
+	}
+}